@@ -0,0 +1,290 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"mymodule/internal/object"
+	"mymodule/internal/storage"
+	"strings"
+	"time"
+)
+
+// Supported archive formats
+const (
+	FormatTar   = "tar"
+	FormatTarGz = "tar.gz"
+	FormatZip   = "zip"
+)
+
+// Result of archiving
+type Result struct {
+	Files int //Number of files written
+	Size  int //Total size of file contents
+}
+
+// Writer of entries in one archive format
+type archiveWriter interface {
+	addDir(name string, mtime time.Time) error
+	addFile(name string, data []byte, mode uint32, mtime time.Time) error
+	Close() error
+}
+
+// Format by name of output file, empty if extension is unknown
+func FormatOf(file string) string {
+	switch {
+	case strings.HasSuffix(file, ".tar.gz"), strings.HasSuffix(file, ".tgz"):
+		return FormatTarGz
+	case strings.HasSuffix(file, ".tar"):
+		return FormatTar
+	case strings.HasSuffix(file, ".zip"):
+		return FormatZip
+	}
+	return ""
+}
+
+// Write tree of commit into archive. Objects are read from storage one by one, working
+// tree is not used. Every name is placed under directory prefix. If paths are given, only
+// files at these paths or under these directories are written.
+func Write(s *storage.Storage, hash []byte, format string, prefix string, paths []string, w io.Writer) (*Result, error) {
+	commitData, err := s.GetCommit(hash)
+	if err != nil {
+		return nil, err
+	}
+	var aw archiveWriter
+	switch format {
+	case FormatTar:
+		aw = &tarWriter{tar.NewWriter(w), nil}
+	case FormatTarGz:
+		gz := gzip.NewWriter(w)
+		aw = &tarWriter{tar.NewWriter(gz), gz}
+	case FormatZip:
+		aw = &zipWriter{zip.NewWriter(w)}
+	default:
+		return nil, fmt.Errorf("unknown archive format %s", format)
+	}
+
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	filters := make([]string, 0, len(paths))
+	for _, p := range paths {
+		p = strings.Trim(p, "/")
+		if p != "" {
+			filters = append(filters, p)
+		}
+	}
+	a := &archiver{
+		s:       s,
+		w:       aw,
+		prefix:  prefix,
+		filters: filters,
+		matched: make([]bool, len(filters)),
+		mtime:   time.Unix(commitData.Commit.Time, 0),
+		result:  &Result{},
+	}
+	if prefix != "" {
+		err = aw.addDir(prefix, a.mtime)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = a.tree(commitData.Commit.Tree, "")
+	if err != nil {
+		return nil, err
+	}
+	for i, matched := range a.matched {
+		if !matched {
+			return nil, fmt.Errorf("path %s not found in commit %x", filters[i], hash)
+		}
+	}
+	err = aw.Close()
+	if err != nil {
+		return nil, err
+	}
+	return a.result, nil
+}
+
+type archiver struct {
+	s       *storage.Storage
+	w       archiveWriter
+	prefix  string
+	filters []string
+	matched []bool    //Whether filter with the same index matched some entry
+	mtime   time.Time //Time of commit, used for all entries
+	result  *Result
+}
+
+// Check if entry at path is written (included) and if its children must be visited
+// (descend): directories on the way to filtered path are visited but not written.
+func (a *archiver) selected(path string) (included bool, descend bool) {
+	if len(a.filters) == 0 {
+		return true, true
+	}
+	for i, f := range a.filters {
+		if path == f || strings.HasPrefix(path, f+"/") {
+			a.matched[i] = true
+			return true, true
+		}
+		if strings.HasPrefix(f, path+"/") {
+			descend = true
+		}
+	}
+	return false, descend
+}
+
+// Write children of tree, dir is path of tree inside archive without prefix
+func (a *archiver) tree(hash []byte, dir string) error {
+	obj, err := a.s.GetObject(hash)
+	if err != nil {
+		return err
+	}
+	tree, err := obj.ParseTree()
+	if err != nil {
+		return err
+	}
+	for _, c := range tree.Children {
+		path := dir + string(c.Name)
+		included, descend := a.selected(path)
+		switch c.Type {
+		case object.TypeTree:
+			if !descend {
+				continue
+			}
+			if included {
+				err = a.w.addDir(a.prefix+path+"/", a.mtime)
+				if err != nil {
+					return err
+				}
+			}
+			err = a.tree(c.Hash, path+"/")
+		case object.TypeBlob:
+			if !included {
+				continue
+			}
+			err = a.blob(c.Hash, path, c.FileMode())
+		default:
+			err = fmt.Errorf("unexpected %s in tree %x", object.TypeToString(c.Type), hash)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Write file, executable or symlink, blob of symlink holds its target
+func (a *archiver) blob(hash []byte, path string, mode uint32) error {
+	obj, err := a.s.GetObject(hash)
+	if err != nil {
+		return err
+	}
+	blob, err := obj.ParseBlob()
+	if err != nil {
+		return err
+	}
+	err = a.w.addFile(a.prefix+path, blob.Data, mode, a.mtime)
+	if err != nil {
+		return err
+	}
+	a.result.Files++
+	a.result.Size += len(blob.Data)
+	return nil
+}
+
+type tarWriter struct {
+	tw *tar.Writer
+	gz *gzip.Writer //Compression of tar stream, nil for plain tar
+}
+
+func (t *tarWriter) addDir(name string, mtime time.Time) error {
+	return t.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     strings.TrimSuffix(name, "/") + "/",
+		Mode:     0755,
+		ModTime:  mtime,
+		Format:   tar.FormatPAX,
+	})
+}
+
+func (t *tarWriter) addFile(name string, data []byte, mode uint32, mtime time.Time) error {
+	header := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     0644,
+		Size:     int64(len(data)),
+		ModTime:  mtime,
+		Format:   tar.FormatPAX,
+	}
+	switch mode {
+	case object.ModeExecutable:
+		header.Mode = 0755
+	case object.ModeSymlink:
+		header.Typeflag = tar.TypeSymlink
+		header.Linkname = string(data)
+		header.Mode = 0777
+		header.Size = 0
+		return t.tw.WriteHeader(header)
+	}
+	err := t.tw.WriteHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = t.tw.Write(data)
+	return err
+}
+
+func (t *tarWriter) Close() error {
+	err := t.tw.Close()
+	if err != nil {
+		return err
+	}
+	if t.gz != nil {
+		return t.gz.Close()
+	}
+	return nil
+}
+
+type zipWriter struct {
+	zw *zip.Writer
+}
+
+func (z *zipWriter) addDir(name string, mtime time.Time) error {
+	header := &zip.FileHeader{
+		Name:     strings.TrimSuffix(name, "/") + "/",
+		Modified: mtime,
+	}
+	header.SetMode(fs.ModeDir | 0755)
+	_, err := z.zw.CreateHeader(header)
+	return err
+}
+
+func (z *zipWriter) addFile(name string, data []byte, mode uint32, mtime time.Time) error {
+	header := &zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: mtime,
+	}
+	// symlink is stored as file with link target and symlink mode, as Info-ZIP does
+	switch mode {
+	case object.ModeExecutable:
+		header.SetMode(0755)
+	case object.ModeSymlink:
+		header.SetMode(fs.ModeSymlink | 0777)
+	default:
+		header.SetMode(0644)
+	}
+	w, err := z.zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func (z *zipWriter) Close() error {
+	return z.zw.Close()
+}
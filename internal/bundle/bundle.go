@@ -0,0 +1,405 @@
+package bundle
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mymodule/internal/object"
+	"mymodule/internal/storage"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Bundle file:
+//
+//	"# vcs bundle v2\n"
+//	"@object-format=<hash algorithm>\n"
+//	"-<hash>\n"           for every prerequisite commit, the receiving repository must have it
+//	"<hash> <branch>\n"   for every branch
+//	"\n"
+//	objects, each is uvarint length followed by object as it is stored in database
+//	sha256 checksum of everything above
+//
+// Bundles of version 1 have no object format line, their hashes are taken to be of
+// algorithm of repository.
+const signature = "# vcs bundle v2"
+
+const signatureV1 = "# vcs bundle v1"
+
+// Header line naming hash algorithm of objects
+const objectFormat = "@object-format="
+
+// Result of bundle creation
+type CreateResult struct {
+	Objects  int
+	Branches []string
+}
+
+// Result of unbundling
+type UnbundleResult struct {
+	Objects  int //Number of objects in bundle
+	Stored   int //Number of new objects written to storage
+	Branches []string
+	Warnings []string //Branches that were not updated
+}
+
+// Write objects reachable from branches into bundle file. Objects reachable from base
+// commits are left out, base commits become prerequisites of bundle.
+func Create(s *storage.Storage, path string, branches []string, bases [][]byte) (*CreateResult, error) {
+	excluded := make(map[string]bool)
+	for _, base := range bases {
+		err := walk(s, base, excluded, func([]byte) bool { return true })
+		if err != nil {
+			return nil, err
+		}
+	}
+	visited := make(map[string]bool)
+	hashes := make([][]byte, 0)
+	for _, branch := range branches {
+		err := walk(s, s.Refs[branch], visited, func(hash []byte) bool {
+			if excluded[string(hash)] {
+				return false
+			}
+			hashes = append(hashes, hash)
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	err = write(s, f, branches, bases, hashes)
+	closeErr := f.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	return &CreateResult{
+		Objects:  len(hashes),
+		Branches: branches,
+	}, nil
+}
+
+// Visit commit and objects it references, fn decides whether to go deeper
+func walk(s *storage.Storage, hash []byte, visited map[string]bool, fn func(hash []byte) bool) error {
+	if len(hash) == 0 || visited[string(hash)] {
+		return nil
+	}
+	visited[string(hash)] = true
+	if !fn(hash) {
+		return nil
+	}
+	obj, err := s.GetObject(hash)
+	if err != nil {
+		return fmt.Errorf("object %x: %w", hash, err)
+	}
+	children, err := references(obj)
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		err = walk(s, child, visited, fn)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Hashes of objects referenced by object
+func references(obj *object.Object) ([][]byte, error) {
+	refs := make([][]byte, 0)
+	switch obj.Type {
+	case object.TypeCommit:
+		commit, err := obj.ParseCommit()
+		if err != nil {
+			return nil, err
+		}
+		refs = append(refs, commit.Tree, commit.Origin)
+		refs = append(refs, commit.Merges...)
+	case object.TypeTree:
+		tree, err := obj.ParseTree()
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range tree.Children {
+			refs = append(refs, c.Hash)
+		}
+	}
+	return refs, nil
+}
+
+func write(s *storage.Storage, f io.Writer, branches []string, bases [][]byte, hashes [][]byte) error {
+	checksum := sha256.New()
+	w := bufio.NewWriter(io.MultiWriter(f, checksum))
+	fmt.Fprintf(w, "%s\n", signature)
+	fmt.Fprintf(w, "%s%s\n", objectFormat, object.HashAlgorithm())
+	for _, base := range bases {
+		fmt.Fprintf(w, "-%x\n", base)
+	}
+	for _, branch := range branches {
+		fmt.Fprintf(w, "%x %s\n", s.Refs[branch], branch)
+	}
+	fmt.Fprintf(w, "\n")
+	for _, hash := range hashes {
+		// objects are copied as stored, without recompression
+		data, err := s.GetData(hash)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(binary.AppendUvarint(nil, uint64(len(data))))
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		if err != nil {
+			return err
+		}
+	}
+	err := w.Flush()
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(checksum.Sum(nil))
+	return err
+}
+
+// Content of bundle file
+type bundle struct {
+	prerequisites [][]byte
+	refs          map[string][]byte
+	objects       map[string]*object.Object //Objects by hex hash
+}
+
+// Verify bundle file and import its objects. Prerequisites must exist in repository and
+// every object reachable from branches of bundle must be in bundle or in repository.
+// Branches are created or fast-forwarded; branches pointing to commits that are not
+// ancestors of bundled ones are overwritten only with force. Files of working tree follow
+// current branch; it is skipped if working tree has changes.
+func Unbundle(s *storage.Storage, path string, force bool) (*UnbundleResult, error) {
+	b, err := read(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, hash := range b.prerequisites {
+		ok, err := s.HasObject(hash)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("repository lacks prerequisite commit %x", hash)
+		}
+	}
+	visited := make(map[string]bool)
+	for _, hash := range b.refs {
+		err = b.checkConnected(s, hash, visited)
+		if err != nil {
+			return nil, err
+		}
+	}
+	stored, _, err := s.StoreObjects(b.objects)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &UnbundleResult{
+		Objects:  len(b.objects),
+		Stored:   stored,
+		Branches: make([]string, 0),
+		Warnings: make([]string, 0),
+	}
+	names := make([]string, 0, len(b.refs))
+	for name := range b.refs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	updates := make(map[string][]byte)
+	var fs *storage.FileSystem
+	for _, branch := range names {
+		hash := b.refs[branch]
+		current, ok := s.Refs[branch]
+		if ok && !force {
+			ff, err := s.IsAncestor(current, hash)
+			if err != nil {
+				return nil, err
+			}
+			if !ff {
+				// branch of new repository still at its empty initial commit
+				ff, err = s.IsInitialCommit(current)
+				if err != nil {
+					return nil, err
+				}
+			}
+			if !ff {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("branch %s skipped: it is not ancestor of bundled commit", branch))
+				continue
+			}
+		}
+		if branch == s.Branch && !bytes.Equal(current, hash) {
+			fs, err = s.MovableWorkTree()
+			if err != nil {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("branch %s skipped: it is checked out and %v", branch, err))
+				continue
+			}
+		}
+		updates[branch] = hash
+		result.Branches = append(result.Branches, branch)
+	}
+	err = s.UpdateBranches(updates, "unbundle: "+path)
+	if err != nil {
+		return nil, err
+	}
+	if fs != nil {
+		err = s.WriteBranchFiles(fs, s.Branch)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// Read and verify bundle file. Branch names and names of tree entries are checked, as
+// they become paths of working tree and of exported refs.
+func read(path string) (*bundle, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	v1 := bytes.HasPrefix(data, []byte(signatureV1+"\n"))
+	if !v1 && !bytes.HasPrefix(data, []byte(signature+"\n")) || len(data) < len(signature)+1+sha256.Size {
+		return nil, fmt.Errorf("%s is not a bundle", path)
+	}
+	content := data[:len(data)-sha256.Size]
+	checksum := sha256.Sum256(content)
+	if !bytes.Equal(checksum[:], data[len(content):]) {
+		return nil, fmt.Errorf("bundle %s is corrupted: checksum mismatch", path)
+	}
+
+	b := &bundle{
+		prerequisites: make([][]byte, 0),
+		refs:          make(map[string][]byte),
+		objects:       make(map[string]*object.Object),
+	}
+	// header lines end with empty line
+	body := content[len(signature)+1:]
+	if !v1 {
+		line, rest, _ := bytes.Cut(body, []byte("\n"))
+		algorithm, ok := bytes.CutPrefix(line, []byte(objectFormat))
+		if !ok {
+			return nil, errors.New("malformed bundle header: object format is missing")
+		}
+		if string(algorithm) != object.HashAlgorithm() {
+			return nil, fmt.Errorf("bundle has %s hashes, repository uses %s", algorithm, object.HashAlgorithm())
+		}
+		body = rest
+	}
+	for {
+		line, rest, found := bytes.Cut(body, []byte("\n"))
+		if !found {
+			return nil, errors.New("malformed bundle header")
+		}
+		body = rest
+		if len(line) == 0 {
+			break
+		}
+		if prerequisite, ok := bytes.CutPrefix(line, []byte("-")); ok {
+			hash, err := parseHash(string(prerequisite))
+			if err != nil {
+				return nil, err
+			}
+			b.prerequisites = append(b.prerequisites, hash)
+			continue
+		}
+		value, branch, found := strings.Cut(string(line), " ")
+		if !found || branch == "" {
+			return nil, fmt.Errorf("malformed bundle header line %q", line)
+		}
+		hash, err := parseHash(value)
+		if err != nil {
+			return nil, err
+		}
+		err = storage.CheckBranchName(branch)
+		if err != nil {
+			return nil, fmt.Errorf("malformed bundle header line %q: %w", line, err)
+		}
+		b.refs[branch] = hash
+	}
+
+	for len(body) > 0 {
+		length, n := binary.Uvarint(body)
+		if n <= 0 || length > uint64(len(body)-n) {
+			return nil, errors.New("malformed bundle object")
+		}
+		data := body[n : n+int(length)]
+		body = body[n+int(length):]
+		obj, err := object.DeserializeObject(data)
+		if err != nil {
+			return nil, err
+		}
+		if obj.Type == object.TypeTree {
+			// parsing checks names of entries, so bad tree never gets stored
+			if _, err := obj.ParseTree(); err != nil {
+				return nil, fmt.Errorf("malformed tree in bundle: %w", err)
+			}
+		}
+		hash, err := obj.GetHash()
+		if err != nil {
+			return nil, err
+		}
+		b.objects[hex.EncodeToString(hash)] = obj
+	}
+	return b, nil
+}
+
+func parseHash(value string) ([]byte, error) {
+	hash, err := hex.DecodeString(value)
+	if err != nil || len(hash) != object.HashSize() {
+		return nil, fmt.Errorf("malformed hash %q in bundle, repository uses %s hashes", value, object.HashAlgorithm())
+	}
+	return hash, nil
+}
+
+// Check that object and everything it references is in bundle or already in repository
+func (b *bundle) checkConnected(s *storage.Storage, hash []byte, visited map[string]bool) error {
+	key := hex.EncodeToString(hash)
+	if len(hash) == 0 || visited[key] {
+		return nil
+	}
+	visited[key] = true
+	obj, ok := b.objects[key]
+	if !ok {
+		// objects of repository are complete, no need to go deeper
+		ok, err := s.HasObject(hash)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("bundle is incomplete: object %x is missing", hash)
+		}
+		return nil
+	}
+	children, err := references(obj)
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		err = b.checkConnected(s, child, visited)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package bundle
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"mymodule/internal/object"
+	"mymodule/internal/storage"
+)
+
+// New repository in temporary directory with files committed, when there are any
+func newRepository(t *testing.T, files map[string]string) *storage.Storage {
+	t.Helper()
+	dir := t.TempDir()
+	s, err := storage.InitStorage(dir, object.HashSHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.CloseStorage)
+	if len(files) == 0 {
+		return s
+	}
+	for name, content := range files {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err = s.CreateCommit("author", "files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// Point master of new repository at initial commit created at another time, as commits
+// of repositories initialised in the same second are equal
+func moveInitialCommit(t *testing.T, s *storage.Storage) {
+	t.Helper()
+	commitData, err := s.GetCommit(s.Refs[storage.MASTER_BRANCH])
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit := *commitData.Commit
+	commit.Time--
+	obj, err := commit.CreateObject()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := obj.GetHash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.SetObject(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.UpdateBranches(map[string][]byte{storage.MASTER_BRANCH: hash}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUnbundleIntoNewRepository(t *testing.T) {
+	src := newRepository(t, map[string]string{"a.txt": "hello\n"})
+	path := filepath.Join(t.TempDir(), "repo.bundle")
+	_, err := Create(src, path, []string{storage.MASTER_BRANCH}, nil)
+	if err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		files   map[string]string
+		updated bool
+	}{
+		{"fresh repository", nil, true},
+		{"repository with own history", map[string]string{"b.txt": "other\n"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := newRepository(t, tt.files)
+			if len(tt.files) == 0 {
+				moveInitialCommit(t, dst)
+			}
+			result, err := Unbundle(dst, path, false)
+			if err != nil {
+				t.Fatalf("Unbundle() error: %v", err)
+			}
+			updated := bytes.Equal(dst.Refs[storage.MASTER_BRANCH], src.Refs[storage.MASTER_BRANCH])
+			if updated != tt.updated {
+				t.Fatalf("master updated = %v, want %v (branches %v, warnings %v)", updated, tt.updated, result.Branches, result.Warnings)
+			}
+			if !updated {
+				if len(result.Warnings) != 1 {
+					t.Errorf("Unbundle() warnings = %v, want one for skipped master", result.Warnings)
+				}
+				return
+			}
+			data, err := os.ReadFile(filepath.Join(dst.Path, "a.txt"))
+			if err != nil || string(data) != "hello\n" {
+				t.Errorf("a.txt = %q, %v, want file of bundled commit", data, err)
+			}
+			changes, err := dst.Diffs()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(changes) != 0 {
+				t.Errorf("working tree has %d changes after unbundle, want clean", len(changes))
+			}
+		})
+	}
+}
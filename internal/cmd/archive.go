@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"mymodule/internal/archive"
+	"os"
+	"strings"
+)
+
+func (cli *CLI) archive(args []string) error {
+	var rev string = ""
+	var format string = ""
+	var output string = ""
+	var prefix string = ""
+	paths := make([]string, 0)
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		// options may be written as "--option=value"
+		name, value, hasValue := strings.Cut(arg, "=")
+		if !strings.HasPrefix(arg, "--") {
+			name, hasValue = arg, false
+		}
+		switch name {
+		case "-h", "--help":
+			fmt.Printf("usage: archive <revision> -o <file>\n")
+			fmt.Printf("   or: archive <revision> --format=<format> -o <file> [--prefix=<dir>/] [<path>...]\n")
+			fmt.Printf("\n")
+			fmt.Printf("Writes files of commit into archive without touching working tree.\n")
+			fmt.Printf("If paths are given, only these files and directories are written.\n")
+			fmt.Printf("\n")
+			fmt.Printf("Available options\n")
+			fmt.Printf("  %-12s    show help (this message)\n", "-h --help")
+			fmt.Printf("  %-12s    archive format: tar, tar.gz or zip\n", "--format")
+			fmt.Printf("  %-12s    default - by extension of file\n", "")
+			fmt.Printf("  %-12s    write archive to file\n", "-o --output")
+			fmt.Printf("  %-12s    put all files under directory\n", "--prefix")
+			return nil
+		case "--format", "-o", "--output", "--prefix":
+			if !hasValue {
+				if i+1 >= len(args) {
+					return fmt.Errorf("Wrong usage of argument %s. Type \"archive -h\" for help.", arg)
+				}
+				value = args[i+1]
+				i++
+			}
+			switch name {
+			case "--format":
+				format = value
+			case "--prefix":
+				prefix = value
+			default:
+				output = value
+			}
+		default:
+			if strings.HasPrefix(arg, "-") {
+				return fmt.Errorf("Unknown argument %s. Type \"archive -h\" for help.", arg)
+			}
+			if rev == "" {
+				rev = arg
+			} else {
+				paths = append(paths, arg)
+			}
+		}
+	}
+	if rev == "" {
+		return errors.New("Revision is not specified. Type \"archive -h\" for help.")
+	}
+	if output == "" {
+		return errors.New("Output file is not specified. Type \"archive -h\" for help.")
+	}
+	if format == "" {
+		format = archive.FormatOf(output)
+		if format == "" {
+			return fmt.Errorf("Can't detect format of %s. Type \"archive -h\" for help.", output)
+		}
+	}
+
+	hash, err := cli.Storage.ResolveRevision(rev)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	result, err := archive.Write(cli.Storage, hash, format, prefix, paths, w)
+	if err == nil {
+		err = w.Flush()
+	}
+	closeErr := f.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(output)
+		return err
+	}
+	fmt.Printf("Archive %s created: %d files, %d bytes\n", output, result.Files, result.Size)
+	return nil
+}
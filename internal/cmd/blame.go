@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"mymodule/internal/history"
+	"mymodule/internal/storage"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func (cli *CLI) blame(args []string) error {
+	var path string = ""
+	var rev string = ""
+	var from, to int = 0, 0
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "-h", "--help":
+			fmt.Printf("usage: blame <path> [<revision>]\n")
+			fmt.Printf("   or: blame <path> [<revision>] -L <start>,<end>\n")
+			fmt.Printf("\n")
+			fmt.Printf("Shows for every line of file the commit that last changed it: short hash,\n")
+			fmt.Printf("author, date and line. Path is relative to repository root, revision is\n")
+			fmt.Printf("HEAD by default. Renamed files are not followed.\n")
+			fmt.Printf("\n")
+			fmt.Printf("Available options\n")
+			fmt.Printf("  %-10s    show help (this message)\n", "-h --help")
+			fmt.Printf("  %-10s    show only lines from start to end, end may be written as +count\n", "-L --lines")
+			return nil
+		case "-L", "--lines":
+			if i+1 >= len(args) {
+				return fmt.Errorf("Wrong usage of argument %s. Type \"blame -h\" for help.", arg)
+			}
+			var err error
+			from, to, err = parseLineRange(args[i+1])
+			if err != nil {
+				return fmt.Errorf("Wrong value of argument %s: %w", arg, err)
+			}
+			i++
+		default:
+			if strings.HasPrefix(arg, "-") {
+				return fmt.Errorf("Unknown argument %s. Type \"blame -h\" for help.", arg)
+			}
+			if path == "" {
+				path = arg
+			} else if rev == "" {
+				rev = arg
+			} else {
+				return fmt.Errorf("Unknown argument %s. Type \"blame -h\" for help.", arg)
+			}
+		}
+	}
+	if path == "" {
+		return errors.New("Path is not specified. Type \"blame -h\" for help.")
+	}
+	if rev == "" {
+		rev = storage.HEAD
+	}
+
+	hash, err := cli.Storage.ResolveRevision(rev)
+	if err != nil {
+		return err
+	}
+	lines, err := history.Blame(cli.Storage, hash, strings.TrimPrefix(path, "./"), from, to)
+	if err != nil {
+		return err
+	}
+	authorWidth, numberWidth := 0, 0
+	for _, line := range lines {
+		authorWidth = max(authorWidth, len(line.Commit.Commit.Author))
+		numberWidth = max(numberWidth, len(strconv.Itoa(line.Number)))
+	}
+	for _, line := range lines {
+		commit := line.Commit.Commit
+		fmt.Printf("%s (%-*s %s %*d) %s\n",
+			fmt.Sprintf("%x", line.Commit.Hash)[:SHORT_HASH],
+			authorWidth, commit.Author,
+			time.Unix(commit.Time, 0).Format("02.01.2006 15:04:05"),
+			numberWidth, line.Number,
+			line.Text,
+		)
+	}
+	return nil
+}
+
+// Parse line range "start,end" or "start,+count", lines are numbered from 1
+func parseLineRange(value string) (int, int, error) {
+	start, end, ok := strings.Cut(value, ",")
+	if !ok {
+		return 0, 0, fmt.Errorf("range \"%s\" is not <start>,<end>", value)
+	}
+	from, err := strconv.Atoi(start)
+	if err != nil || from < 1 {
+		return 0, 0, fmt.Errorf("wrong start of range \"%s\"", value)
+	}
+	count, isCount := strings.CutPrefix(end, "+")
+	to, err := strconv.Atoi(count)
+	if err != nil || to < 1 {
+		return 0, 0, fmt.Errorf("wrong end of range \"%s\"", value)
+	}
+	if isCount {
+		to = from + to - 1
+	}
+	if to < from {
+		return 0, 0, fmt.Errorf("end of range \"%s\" is before start", value)
+	}
+	return from, to, nil
+}
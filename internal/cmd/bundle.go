@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"mymodule/internal/bundle"
+	"mymodule/internal/storage"
+	"strings"
+)
+
+func (cli *CLI) bundle(args []string) error {
+	if len(args) == 0 {
+		return errors.New("Wrong usage of bundle. Type \"bundle -h\" for help.")
+	}
+	switch args[0] {
+	case "-h", "--help":
+		fmt.Printf("usage: bundle create <file> <branch>... [--base <revision>]...\n")
+		fmt.Printf("   or: bundle unbundle [-f] <file>\n")
+		fmt.Printf("\n")
+		fmt.Printf("Moves history between repositories through a single file.\n")
+		fmt.Printf("\n")
+		fmt.Printf("Commands\n")
+		fmt.Printf("  %-10s    write objects reachable from branches and branch names to file\n", "create")
+		fmt.Printf("  %-10s    verify file, import its objects, create or fast-forward branches\n", "unbundle")
+		fmt.Printf("\n")
+		fmt.Printf("Available options\n")
+		fmt.Printf("  %-10s    show help (this message)\n", "-h --help")
+		fmt.Printf("  %-10s    leave out objects reachable from revision, receiver must have it\n", "--base")
+		fmt.Printf("  %-10s    overwrite branches that can't be fast-forwarded\n", "-f --force")
+		return nil
+	case "create":
+		return cli.bundleCreate(args[1:])
+	case "unbundle":
+		return cli.unbundle(args[1:])
+	default:
+		return fmt.Errorf("Unknown argument %s. Type \"bundle -h\" for help.", args[0])
+	}
+}
+
+func (cli *CLI) bundleCreate(args []string) error {
+	var file string = ""
+	branches := make([]string, 0)
+	bases := make([][]byte, 0)
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "--base":
+			if i+1 >= len(args) {
+				return fmt.Errorf("Wrong usage of argument %s. Type \"bundle -h\" for help.", arg)
+			}
+			hash, err := cli.Storage.ResolveRevision(args[i+1])
+			if err != nil {
+				return err
+			}
+			bases = append(bases, hash)
+			i++
+		default:
+			if strings.HasPrefix(arg, "-") {
+				return fmt.Errorf("Unknown argument %s. Type \"bundle -h\" for help.", arg)
+			}
+			if file == "" {
+				file = arg
+				continue
+			}
+			if arg == storage.HEAD {
+				arg = cli.Storage.Branch
+			}
+			if cli.Storage.Refs[arg] == nil {
+				return fmt.Errorf("branch \"%s\" does not exist", arg)
+			}
+			branches = append(branches, arg)
+		}
+	}
+	if file == "" || len(branches) == 0 {
+		return errors.New("File and branches must be specified. Type \"bundle -h\" for help.")
+	}
+
+	result, err := bundle.Create(cli.Storage, file, branches, bases)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Bundle %s created: %d objects\n", file, result.Objects)
+	fmt.Printf("Branches: %s\n", strings.Join(result.Branches, ", "))
+	return nil
+}
+
+func (cli *CLI) unbundle(args []string) error {
+	var file string = ""
+	var force bool = false
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "-f", "--force":
+			force = true
+		default:
+			if file == "" && !strings.HasPrefix(arg, "-") {
+				file = arg
+			} else {
+				return fmt.Errorf("Unknown argument %s. Type \"bundle -h\" for help.", arg)
+			}
+		}
+	}
+	if file == "" {
+		return errors.New("File is not specified. Type \"bundle -h\" for help.")
+	}
+
+	result, err := bundle.Unbundle(cli.Storage, file, force)
+	if err != nil {
+		return err
+	}
+	for _, w := range result.Warnings {
+		fmt.Printf("warning: %s\n", w)
+	}
+	fmt.Printf("Unbundled %d objects, %d new objects stored\n", result.Objects, result.Stored)
+	fmt.Printf("Branches: %s\n", strings.Join(result.Branches, ", "))
+	return nil
+}
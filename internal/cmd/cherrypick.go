@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"mymodule/internal/sequencer"
+	"strings"
+)
+
+func (cli *CLI) cherryPick(args []string) error {
+	var action string = ""
+	revs := make([]string, 0)
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "-h", "--help":
+			fmt.Printf("usage: cherry-pick <revision>...\n")
+			fmt.Printf("   or: cherry-pick --continue\n")
+			fmt.Printf("   or: cherry-pick --abort\n")
+			fmt.Printf("\n")
+			fmt.Printf("Applies changes made by commits, each relative to its first parent, onto\n")
+			fmt.Printf("current branch. Every commit gets a new one with the same author and\n")
+			fmt.Printf("description, noting hash of original. Working tree must be clean.\n")
+			fmt.Printf("\n")
+			fmt.Printf("Files changed by commit and on current branch are merged by lines. If changes\n")
+			fmt.Printf("conflict, files get both versions between markers and cherry-pick stops:\n")
+			fmt.Printf("edit them and continue, or abort to return to the state before cherry-pick.\n")
+			fmt.Printf("\n")
+			fmt.Printf("Available options\n")
+			fmt.Printf("  %-10s    show help (this message)\n", "-h --help")
+			fmt.Printf("  %-10s    commit resolved files and apply the rest of commits\n", "--continue")
+			fmt.Printf("  %-10s    cancel cherry-pick, changes of files are lost\n", "--abort")
+			return nil
+		case "--continue", "--abort":
+			if action != "" {
+				return fmt.Errorf("Unknown argument %s. Type \"cherry-pick -h\" for help.", arg)
+			}
+			action = arg
+		default:
+			if strings.HasPrefix(arg, "-") {
+				return fmt.Errorf("Unknown argument %s. Type \"cherry-pick -h\" for help.", arg)
+			}
+			revs = append(revs, arg)
+		}
+	}
+	if action != "" && len(revs) > 0 {
+		return fmt.Errorf("Unknown argument %s. Type \"cherry-pick -h\" for help.", revs[0])
+	}
+
+	var steps []*sequencer.Step
+	var err error
+	switch action {
+	case "--abort":
+		return cli.abortSequence(sequencer.CHERRY_PICK)
+	case "--continue":
+		steps, err = sequencer.Continue(cli.Storage, sequencer.CHERRY_PICK)
+	default:
+		if len(revs) == 0 {
+			return errors.New("Revision is not specified. Type \"cherry-pick -h\" for help.")
+		}
+		var commits [][]byte
+		commits, err = cli.resolveCommits(revs)
+		if err != nil {
+			return err
+		}
+		steps, err = sequencer.CherryPick(cli.Storage, commits)
+	}
+	printSteps(steps)
+	return err
+}
+
+// Hashes of revisions
+func (cli *CLI) resolveCommits(revs []string) ([][]byte, error) {
+	commits := make([][]byte, 0, len(revs))
+	for _, rev := range revs {
+		hash, err := cli.Storage.ResolveRevision(rev)
+		if err != nil {
+			return nil, err
+		}
+		commits = append(commits, hash)
+	}
+	return commits, nil
+}
+
+// Cancel operation replaying commits and report where branch is left
+func (cli *CLI) abortSequence(operation string) error {
+	err := sequencer.Abort(cli.Storage, operation)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s%s aborted, %s is at %x\n", strings.ToUpper(operation[:1]), operation[1:],
+		cli.Storage.Branch, cli.Storage.Refs[cli.Storage.Branch])
+	return nil
+}
+
+// Report commits made while replaying
+func printSteps(steps []*sequencer.Step) {
+	for _, step := range steps {
+		source := fmt.Sprintf("%x", step.Source)[:SHORT_HASH]
+		if step.Commit == nil {
+			fmt.Printf("Skipped %s: no changes left to commit\n", source)
+			continue
+		}
+		fmt.Printf("Commit %x created from %s\n", step.Commit, source)
+	}
+}
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"mymodule/internal/object"
 	"mymodule/internal/storage"
@@ -17,70 +18,218 @@ import (
 )
 
 type CLI struct {
-	Storage *storage.Storage
+	Dir     string           //Directory repository is searched from
+	Path    string           //Path to repository of currently running command
+	Storage *storage.Storage //Repository opened for currently running command
+	JSON    bool             //Print output of currently running command as JSON
 }
 
-func InitCLI(path string) *CLI {
-	storage, err := storage.InitStorage(path)
-	if err != nil {
-		fmt.Println(err.Error())
-	}
+// Commands working with repository. Value is true for commands that only inspect it:
+// they open repository read-only and may run in parallel with each other.
+var repoCommands = map[string]bool{
+	"commit":      false,
+	"checkout":    false,
+	"migrate":     false,
+	"import-git":  false,
+	"bundle":      false,
+	"cherry-pick": false,
+	"revert":      false,
+	"rebase":      false,
+	"stash":       false,
+	"reset":       false,
+	"branch":      true,
+	"diff":        true,
+	"show":        true,
+	"status":      true,
+	"log":         true,
+	"blame":       true,
+	"reflog":      true,
+	"export-git":  true,
+	"archive":     true,
+}
+
+// Commands able to print output as JSON
+var jsonCommands = map[string]bool{
+	"commit": true,
+	"branch": true,
+	"diff":   true,
+	"show":   true,
+	"status": true,
+}
+
+// Create CLI working with repository containing directory dir
+func InitCLI(dir string) *CLI {
 	cli := CLI{
-		Storage: storage,
+		Dir: dir,
 	}
 	return &cli
 }
 
-func (cli *CLI) Execute(command string) {
+// Open repository for one command. Repository is not kept open between commands,
+// so other processes can use it meanwhile; it is searched for again every time.
+func (cli *CLI) open(readOnly bool) error {
+	path, err := storage.FindRepository(cli.Dir)
+	if err != nil {
+		return err
+	}
+	cli.Path = path
+	storage, err := storage.OpenStorage(cli.Path, readOnly)
+	if err != nil {
+		return err
+	}
+	cli.Storage = storage
+	return nil
+}
+
+func (cli *CLI) close() {
+	if cli.Storage != nil {
+		cli.Storage.CloseStorage()
+		cli.Storage = nil
+	}
+}
+
+// Run command line typed in shell. Error is reported before it is returned.
+func (cli *CLI) Execute(command string) error {
 	commandSplit, err := shlex.Split(command)
 	if err != nil {
-		fmt.Println(err.Error())
+		cli.JSON = false
+		cli.printError(err)
+		return err
 	}
-	if len(commandSplit) == 0 {
+	return cli.ExecuteArgs(commandSplit)
+}
+
+// Run command given as words. Error is reported before it is returned.
+func (cli *CLI) ExecuteArgs(commandSplit []string) error {
+	// --json may be given anywhere in command
+	cli.JSON = false
+	words := make([]string, 0, len(commandSplit))
+	for _, word := range commandSplit {
+		if word == JSON_OPTION {
+			cli.JSON = true
+		} else {
+			words = append(words, word)
+		}
+	}
+	if len(words) == 0 {
+		return nil
+	}
+	err := cli.run(words[0], words[1:])
+	if err != nil {
+		cli.printError(err)
+	}
+	return err
+}
+
+// Report error of command as text to stderr or as JSON object
+func (cli *CLI) printError(err error) {
+	if cli.JSON {
+		printJSON(jsonError{err.Error()})
 		return
 	}
-	cmd := commandSplit[0]
-	args := commandSplit[1:]
+	fmt.Fprintln(os.Stderr, err.Error())
+}
+
+func (cli *CLI) run(cmd string, args []string) error {
 	switch cmd {
 	case "help":
 		fmt.Printf("Available command:\n")
-		fmt.Printf("  %-8s - show help\n", "help")
-		fmt.Printf("  %-8s - create new commit\n", "commit")
-		fmt.Printf("  %-8s - show info about branches\n", "branch")
-		fmt.Printf("  %-8s - switch branches\n", "checkout")
-		fmt.Printf("  %-8s - show differences between versions\n", "diff")
-		fmt.Printf("  %-8s - show info about objects\n", "show")
-		fmt.Printf("  %-8s - exit program\n", "exit")
+		fmt.Printf("  %-11s - show help\n", "help")
+		fmt.Printf("  %-11s - create repository\n", "init")
+		fmt.Printf("  %-11s - create new commit\n", "commit")
+		fmt.Printf("  %-11s - show info about branches\n", "branch")
+		fmt.Printf("  %-11s - show history with graph\n", "log")
+		fmt.Printf("  %-11s - show commit that last changed each line of file\n", "blame")
+		fmt.Printf("  %-11s - switch branches\n", "checkout")
+		fmt.Printf("  %-11s - apply changes of commits onto current branch\n", "cherry-pick")
+		fmt.Printf("  %-11s - undo changes of commits with new commits\n", "revert")
+		fmt.Printf("  %-11s - replay commits of current branch on top of another\n", "rebase")
+		fmt.Printf("  %-11s - save changes of working tree aside and bring them back\n", "stash")
+		fmt.Printf("  %-11s - move current branch to another commit\n", "reset")
+		fmt.Printf("  %-11s - show differences between versions\n", "diff")
+		fmt.Printf("  %-11s - show info about objects\n", "show")
+		fmt.Printf("  %-11s - show changes of working tree\n", "status")
+		fmt.Printf("  %-11s - show history of ref movements\n", "reflog")
+		fmt.Printf("  %-11s - rewrite objects into current encoding\n", "migrate")
+		fmt.Printf("  %-11s - export history into git repository\n", "export-git")
+		fmt.Printf("  %-11s - import history from git repository\n", "import-git")
+		fmt.Printf("  %-11s - write files of commit into archive\n", "archive")
+		fmt.Printf("  %-11s - move history through a file\n", "bundle")
+		fmt.Printf("  %-11s - exit program\n", "exit")
+		fmt.Printf("\n")
+		fmt.Printf("Option %s prints output of %s as JSON.\n", JSON_OPTION, "commit, branch, diff, show, status")
+		fmt.Printf("Repository is searched in current directory and its parents, options -C <dir> or\n")
+		fmt.Printf("--repo <dir> given before command start search from dir.\n")
+		return nil
+	case "exit":
+		cli.Exit()
+		os.Exit(0)
+		return nil
+	case "init":
+		if cli.JSON {
+			return fmt.Errorf("Command %s does not support %s.", cmd, JSON_OPTION)
+		}
+		return cli.initRepository(args)
+	}
 
-		return
+	readOnly, ok := repoCommands[cmd]
+	if !ok {
+		return fmt.Errorf("Unknown command %s. Type \"help\" for help.", cmd)
+	}
+	if cli.JSON && !jsonCommands[cmd] {
+		return fmt.Errorf("Command %s does not support %s.", cmd, JSON_OPTION)
+	}
+	err := cli.open(readOnly)
+	if err != nil {
+		return err
+	}
+	defer cli.close()
+
+	switch cmd {
 	case "diff":
-		cli.diff(args)
-		return
+		return cli.diff(args)
 	case "commit":
-		cli.commit(args)
-		return
+		return cli.commit(args)
 	case "branch":
-		cli.branch(args)
-		return
+		return cli.branch(args)
 	case "show":
-		cli.show(args)
-		return
+		return cli.show(args)
+	case "status":
+		return cli.status(args)
 	case "checkout":
-		cli.checkout(args)
-		return
-	case "exit":
-		cli.Exit()
-		os.Exit(0)
-		return
-	default:
-		fmt.Printf("Unknown command %s. Type \"help\" for help.\n", cmd)
-		return
+		return cli.checkout(args)
+	case "reflog":
+		return cli.reflog(args)
+	case "migrate":
+		return cli.migrate(args)
+	case "export-git":
+		return cli.exportGit(args)
+	case "import-git":
+		return cli.importGit(args)
+	case "archive":
+		return cli.archive(args)
+	case "bundle":
+		return cli.bundle(args)
+	case "log":
+		return cli.log(args)
+	case "blame":
+		return cli.blame(args)
+	case "cherry-pick":
+		return cli.cherryPick(args)
+	case "revert":
+		return cli.revert(args)
+	case "rebase":
+		return cli.rebase(args)
+	case "stash":
+		return cli.stash(args)
+	case "reset":
+		return cli.reset(args)
 	}
+	return nil
 }
-func (cli *CLI) commit(args []string) {
+func (cli *CLI) commit(args []string) error {
 	if len(args) == 0 {
-		fmt.Printf("Wrong usage of commit. Type \"commit -h\" for help.\n")
-		return
+		return errors.New("Wrong usage of commit. Type \"commit -h\" for help.")
 	}
 	var author string = ""
 	var description string = ""
@@ -100,42 +249,62 @@ func (cli *CLI) commit(args []string) {
 			fmt.Printf("  %-16s    set commit's description\n", "-d --description")
 			fmt.Printf("  %-16s    default - \"\"\n", "")
 
-			return
+			return nil
 		case "-a", "--author":
 			if i+1 >= len(args) {
-				fmt.Printf("Wrong usage of argument %s. Type \"commit -h\" for help.\n", arg)
-				return
+				return fmt.Errorf("Wrong usage of argument %s. Type \"commit -h\" for help.", arg)
 			}
 			author = args[i+1]
 			i++
 		case "-d", "--description":
 			if i+1 >= len(args) {
-				fmt.Printf("Wrong usage of argument %s. Type \"commit -h\" for help.\n", arg)
-				return
+				return fmt.Errorf("Wrong usage of argument %s. Type \"commit -h\" for help.", arg)
 			}
 			description = args[i+1]
 			i++
 		default:
-			fmt.Printf("Unknown argument %s. Type \"commit -h\" for help.\n", arg)
-			return
+			return fmt.Errorf("Unknown argument %s. Type \"commit -h\" for help.", arg)
 		}
 	}
 	if author == "" {
 		user, err := user.Current()
 		if err != nil {
-			fmt.Printf("User is not specified. Type \"commit -h\" for help.\n")
+			return errors.New("User is not specified. Type \"commit -h\" for help.")
 		}
 		author = user.Username
 	}
-	err := cli.Storage.CreateCommit(author, description)
+	result, err := cli.Storage.CreateCommit(author, description)
 	if err != nil {
-		fmt.Println(err.Error())
+		return err
+	}
+	if cli.JSON {
+		return printJSON(jsonCommitResult{
+			Hash:   hex.EncodeToString(result.Hash),
+			Branch: cli.Storage.Branch,
+			Stored: result.Stored,
+			Reused: result.Reused,
+		})
 	}
-	fmt.Println("Commit created")
+	fmt.Printf("Commit %x created: %d new objects stored, %d reused\n", result.Hash, result.Stored, result.Reused)
+	return nil
 }
-func (cli *CLI) branch(args []string) {
+func (cli *CLI) branch(args []string) error {
 	if len(args) == 0 {
 		branches := cli.Storage.GetBranches()
+		if cli.JSON {
+			out := jsonBranches{
+				Current:  cli.Storage.Branch,
+				Branches: make([]jsonBranch, 0, len(branches)),
+			}
+			for _, branch := range branches {
+				out.Branches = append(out.Branches, jsonBranch{
+					Name:    branch,
+					Hash:    hex.EncodeToString(cli.Storage.Refs[branch]),
+					Current: branch == cli.Storage.Branch,
+				})
+			}
+			return printJSON(out)
+		}
 		for _, branch := range branches {
 			fmt.Printf("%s", branch)
 			if branch == cli.Storage.Branch {
@@ -143,7 +312,7 @@ func (cli *CLI) branch(args []string) {
 			}
 			fmt.Printf("\n")
 		}
-		return
+		return nil
 	}
 	var branch string = ""
 	var count uint64 = 5
@@ -164,17 +333,16 @@ func (cli *CLI) branch(args []string) {
 			fmt.Printf("  %-12s    show all commits\n", "-a --all")
 			fmt.Printf("  %-12s    set commit's limit\n", "-c --count")
 			fmt.Printf("  %-12s    default: \"5\"\n", "")
-			return
+			return nil
 		case "-a", "--all":
 			count = 0
 		case "-c", "--count":
 			if i+1 >= len(args) {
-				fmt.Printf("Wrong usage of argument %s. Type \"branch -h\" for help.\n", arg)
-				return
+				return fmt.Errorf("Wrong usage of argument %s. Type \"branch -h\" for help.", arg)
 			}
 			c, err := strconv.ParseUint(args[i+1], 10, 64)
 			if err != nil {
-				fmt.Println(err.Error())
+				return err
 			}
 			count = c
 			i++
@@ -184,8 +352,7 @@ func (cli *CLI) branch(args []string) {
 			if branch == "" {
 				branch = arg
 			} else {
-				fmt.Printf("Unknown argument %s. Type \"branch -h\" for help.\n", arg)
-				return
+				return fmt.Errorf("Unknown argument %s. Type \"branch -h\" for help.", arg)
 			}
 		}
 	}
@@ -194,8 +361,17 @@ func (cli *CLI) branch(args []string) {
 	}
 	commits, err := cli.Storage.GetCommits(branch, count)
 	if err != nil {
-		fmt.Println(err.Error())
-		return
+		return err
+	}
+	if cli.JSON {
+		out := jsonBranchLog{
+			Branch:  branch,
+			Commits: make([]jsonCommit, 0, len(commits)),
+		}
+		for _, commitData := range commits {
+			out.Commits = append(out.Commits, newJSONCommit(commitData.Hash, commitData.Commit))
+		}
+		return printJSON(out)
 	}
 	fmt.Printf("Branch: %s\n", branch)
 	fmt.Printf("\n---------------------------------------------------------------------------\n\n")
@@ -206,6 +382,9 @@ func (cli *CLI) branch(args []string) {
 			fmt.Printf("Author:        %s\n", commitData.Commit.Author)
 			fmt.Printf("Time:          %s\n", time.Unix(commitData.Commit.Time, 0).Format("02.01.2006 15:04:05"))
 			fmt.Printf("Origin:        %x\n", commitData.Commit.Origin)
+			for _, m := range commitData.Commit.Merges {
+				fmt.Printf("Merge:         %x\n", m)
+			}
 			fmt.Printf("Tree:          %x\n", commitData.Commit.Tree)
 			if i != len(commits)-1 {
 				fmt.Printf("\n---------------------------------------------------------------------------\n\n")
@@ -214,8 +393,9 @@ func (cli *CLI) branch(args []string) {
 			fmt.Printf("%x\n", commitData.Hash)
 		}
 	}
+	return nil
 }
-func (cli *CLI) diff(args []string) {
+func (cli *CLI) diff(args []string) error {
 	var verbose bool = false
 	hashes := make([][]byte, 0)
 
@@ -225,26 +405,24 @@ func (cli *CLI) diff(args []string) {
 		case "-h", "--help":
 			fmt.Printf("usage: diff\n")
 			fmt.Printf("   or: diff -v\n")
-			fmt.Printf("   or: diff <commit>\n")
-			fmt.Printf("   or: diff <commit1> <commit2>\n")
+			fmt.Printf("   or: diff <revision>\n")
+			fmt.Printf("   or: diff <revision1> <revision2>\n")
 			fmt.Printf("\n")
 			fmt.Printf("Available options\n")
 			fmt.Printf("  %-12s    show help (this message)\n", "-h --help")
 			fmt.Printf("  %-12s    enable verbose output\n", "-v --verbose")
-			return
+			return nil
 		case "-v", "--verbose":
 			verbose = true
 		default:
 			if len(hashes) < 2 {
-				hash, err := hex.DecodeString(arg)
+				hash, err := cli.Storage.ResolveRevision(arg)
 				if err != nil {
-					fmt.Printf("%s is not hash", arg)
-					return
+					return err
 				}
 				hashes = append(hashes, hash)
 			} else {
-				fmt.Printf("Unknown argument %s. Type \"diff -h\" for help.\n", arg)
-				return
+				return fmt.Errorf("Unknown argument %s. Type \"diff -h\" for help.", arg)
 			}
 		}
 	}
@@ -253,27 +431,48 @@ func (cli *CLI) diff(args []string) {
 	switch len(hashes) {
 	case 0:
 		changes, err = cli.Storage.Diffs()
-		if err != nil {
-			fmt.Println(err.Error())
-			return
-		}
 	case 1:
 		changes, err = cli.Storage.DiffsWithCommit(hashes[0])
-		if err != nil {
-			fmt.Println(err.Error())
-			return
-		}
 	case 2:
 		changes, err = cli.Storage.DiffsBetweenCommits(hashes[0], hashes[1])
-		if err != nil {
-			fmt.Println(err.Error())
-			return
+	}
+	if err != nil {
+		return err
+	}
+
+	if cli.JSON {
+		out := jsonDiff{
+			Files: make([]jsonFileDiff, 0, len(changes)),
+		}
+		switch len(hashes) {
+		case 0:
+			out.From = hex.EncodeToString(cli.Storage.Refs[cli.Storage.Branch])
+		case 1:
+			out.From = hex.EncodeToString(hashes[0])
+		case 2:
+			out.From = hex.EncodeToString(hashes[0])
+			out.To = hex.EncodeToString(hashes[1])
 		}
+		for _, c := range changes {
+			out.Files = append(out.Files, newJSONFileDiff(c))
+		}
+		return printJSON(out)
 	}
+	printChanges(changes, verbose)
+	return nil
+}
 
+// Print changed files with counts of deleted and inserted lines, or whole changes if verbose
+func printChanges(changes []*object.FileChange, verbose bool) {
 	for i, c := range changes {
 		if verbose {
 			fmt.Printf("Filename:  %s\n", c.FileName)
+			if modeChanged(c) {
+				fmt.Printf("Mode:      %06o -> %06o\n", c.OldMode, c.NewMode)
+			}
+			if isDirectory(c) {
+				fmt.Printf("Directory: %s\n", c.Status)
+			}
 			fmt.Printf("Changes:\n")
 			fmt.Println(diffmatchpatch.New().DiffPrettyText(c.Changes))
 			if i != len(changes)-1 {
@@ -290,20 +489,102 @@ func (cli *CLI) diff(args []string) {
 					delete++
 				}
 			}
+			mode := ""
+			if modeChanged(c) {
+				mode = fmt.Sprintf(" (mode %06o -> %06o)", c.OldMode, c.NewMode)
+			}
+			if isDirectory(c) {
+				mode = fmt.Sprintf(" (directory %s)", c.Status)
+			}
 			fmt.Printf(
-				"%s %s%s\n",
+				"%s %s%s%s\n",
 				c.FileName,
 				color.RedString("%s", strings.Repeat("-", delete)),
 				color.GreenString("%s", strings.Repeat("+", insert)),
+				mode,
 			)
 		}
 	}
 }
 
-func (cli *CLI) checkout(args []string) {
+func (cli *CLI) status(args []string) error {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "-h", "--help":
+			fmt.Printf("usage: status\n")
+			fmt.Printf("\n")
+			fmt.Printf("Shows current branch and files changed since its last commit.\n")
+			fmt.Printf("\n")
+			fmt.Printf("Available options\n")
+			fmt.Printf("  %-9s    show help (this message)\n", "-h --help")
+			return nil
+		default:
+			return fmt.Errorf("Unknown argument %s. Type \"status -h\" for help.", arg)
+		}
+	}
+	changes, err := cli.Storage.Diffs()
+	if err != nil {
+		return err
+	}
+	seq, err := cli.Storage.GetSequencer()
+	if err != nil {
+		return err
+	}
+	head := cli.Storage.Refs[cli.Storage.Branch]
+	if cli.JSON {
+		out := jsonStatus{
+			Branch: cli.Storage.Branch,
+			Head:   hex.EncodeToString(head),
+			Clean:  len(changes) == 0,
+			Files:  make([]jsonFileStatus, 0, len(changes)),
+		}
+		if seq != nil {
+			out.Operation, out.Conflicts = seq.Operation, seq.Conflicts
+		}
+		for _, c := range changes {
+			out.Files = append(out.Files, jsonFileStatus{
+				Path:    string(c.FileName),
+				Status:  c.Status,
+				OldMode: jsonMode(c.OldMode),
+				NewMode: jsonMode(c.NewMode),
+			})
+		}
+		return printJSON(out)
+	}
+	fmt.Printf("On branch %s\n", cli.Storage.Branch)
+	fmt.Printf("Head:      %x\n\n", head)
+	if seq != nil {
+		fmt.Printf("%s is in progress", seq.Operation)
+		if len(seq.Conflicts) > 0 {
+			fmt.Printf(", conflicts in: %s", strings.Join(seq.Conflicts, ", "))
+		}
+		fmt.Printf("\nUse \"%s --continue\" or \"%s --abort\"\n\n", seq.Operation, seq.Operation)
+	}
+	if len(changes) == 0 {
+		fmt.Printf("Nothing to commit, working tree clean\n")
+		return nil
+	}
+	fmt.Printf("Changes:\n")
+	for _, c := range changes {
+		text := fmt.Sprintf("%-10s %s", c.Status+":", c.FileName)
+		if modeChanged(c) {
+			text += fmt.Sprintf(" (mode %06o -> %06o)", c.OldMode, c.NewMode)
+		}
+		switch c.Status {
+		case object.StatusAdded:
+			text = color.GreenString("%s", text)
+		case object.StatusDeleted:
+			text = color.RedString("%s", text)
+		}
+		fmt.Printf("  %s\n", text)
+	}
+	return nil
+}
+
+func (cli *CLI) checkout(args []string) error {
 	if len(args) == 0 {
-		fmt.Printf("Wrong usage of checkout. Type \"checkout -h\" for help.\n")
-		return
+		return errors.New("Wrong usage of checkout. Type \"checkout -h\" for help.")
 	}
 
 	var b bool = false
@@ -315,41 +596,41 @@ func (cli *CLI) checkout(args []string) {
 			fmt.Printf("usage: checkout <branch>\n")
 			fmt.Printf("   or: checkout -b <branch>\n")
 			fmt.Printf("\n")
+			fmt.Printf("Replaces files of working tree with files of branch.\n")
+			fmt.Printf("Working tree must have no uncommitted changes.\n")
+			fmt.Printf("\n")
 			fmt.Printf("Available options\n")
 			fmt.Printf("  %-9s    show help (this message)\n", "-h --help")
 			fmt.Printf("  %-9s    Create branch and switch\n", "-b")
-			return
+			return nil
 		case "-b":
 			b = true
 		default:
 			if branch == "" {
 				branch = arg
 			} else {
-				fmt.Printf("Unknown argument %s. Type \"checkout -h\" for help.\n", arg)
-				return
+				return fmt.Errorf("Unknown argument %s. Type \"checkout -h\" for help.", arg)
 			}
 		}
 	}
 	if branch == "" {
-		fmt.Printf("Branch name is not specified. Type \"checkout -h\" for help.\n")
-		return
+		return errors.New("Branch name is not specified. Type \"checkout -h\" for help.")
 	}
+	var err error
 	if b {
-		err := cli.Storage.CreateBranch(branch)
-		if err != nil {
-			fmt.Println(err.Error())
-		}
+		err = cli.Storage.CreateAndChangeBranch(branch)
+	} else {
+		err = cli.Storage.Checkout(branch)
 	}
-	err := cli.Storage.ChangeBranch(branch)
 	if err != nil {
-		fmt.Println(err.Error())
+		return err
 	}
 	fmt.Printf("Current branch is %s.\n", cli.Storage.Branch)
+	return nil
 }
-func (cli *CLI) show(args []string) {
+func (cli *CLI) show(args []string) error {
 	if len(args) == 0 {
-		fmt.Printf("Wrong usage of show. Type \"show -h\" for help.\n")
-		return
+		return errors.New("Wrong usage of show. Type \"show -h\" for help.")
 	}
 
 	var hash []byte = nil
@@ -359,33 +640,32 @@ func (cli *CLI) show(args []string) {
 		arg := args[i]
 		switch arg {
 		case "-h", "--help":
-			fmt.Printf("usage: show <hash>\n")
+			fmt.Printf("usage: show <revision>\n")
 			fmt.Printf("\n")
 			fmt.Printf("Available options\n")
 			fmt.Printf("  %-9s    show help (this message)\n", "-h --help")
-			return
+			return nil
 		default:
 			if hash == nil {
-				hash, err = hex.DecodeString(arg)
+				hash, err = cli.Storage.ResolveRevision(arg)
 				if err != nil {
-					fmt.Printf("%s is not hash", arg)
-					return
+					return err
 				}
 			} else {
-				fmt.Printf("Unknown argument %s. Type \"show -h\" for help.\n", arg)
-				return
+				return fmt.Errorf("Unknown argument %s. Type \"show -h\" for help.", arg)
 			}
 		}
 	}
 
 	if hash == nil {
-		fmt.Printf("Wrong usage of show. Type \"show -h\" for help.\n")
-		return
+		return errors.New("Wrong usage of show. Type \"show -h\" for help.")
 	}
 	obj, err := cli.Storage.GetObject(hash)
 	if err != nil {
-		fmt.Println(err.Error())
-		return
+		return err
+	}
+	if cli.JSON {
+		return cli.showJSON(hash, obj)
 	}
 	fmt.Printf("Hash:          %x\n", hash)
 	fmt.Printf("Type:          %s\n\n", object.TypeToString(obj.Type))
@@ -393,8 +673,7 @@ func (cli *CLI) show(args []string) {
 	case object.TypeBlob:
 		blob, err := obj.ParseBlob()
 		if err != nil {
-			fmt.Println(err.Error())
-			return
+			return err
 		}
 		fmt.Printf("---------- Content ----------\n")
 		fmt.Println(string(blob.Data))
@@ -403,29 +682,90 @@ func (cli *CLI) show(args []string) {
 	case object.TypeTree:
 		tree, err := obj.ParseTree()
 		if err != nil {
-			fmt.Println(err.Error())
-			return
+			return err
 		}
 		for _, c := range tree.Children {
-			fmt.Printf("%-10s%x      %s\n", object.TypeToString(c.Type), c.Hash, c.Name)
+			fmt.Printf("%06o  %-10s%x      %s\n", c.FileMode(), object.TypeToString(c.Type), c.Hash, c.Name)
 		}
 	case object.TypeCommit:
 		commit, err := obj.ParseCommit()
 		if err != nil {
-			fmt.Println(err.Error())
-			return
+			return err
 		}
 
 		fmt.Printf("Description:   %s\n", commit.Description)
 		fmt.Printf("Author:        %s\n", commit.Author)
 		fmt.Printf("Time:          %s\n", time.Unix(commit.Time, 0).Format("02.01.2006 15:04:05"))
 		fmt.Printf("Origin:        %x\n", commit.Origin)
+		for _, m := range commit.Merges {
+			fmt.Printf("Merge:         %x\n", m)
+		}
 		fmt.Printf("Tree:          %x\n", commit.Tree)
 	}
+	return nil
+}
+
+func (cli *CLI) showJSON(hash []byte, obj *object.Object) error {
+	switch obj.Type {
+	case object.TypeBlob:
+		blob, err := obj.ParseBlob()
+		if err != nil {
+			return err
+		}
+		return printJSON(newJSONBlob(hash, blob.Data))
+	case object.TypeTree:
+		tree, err := obj.ParseTree()
+		if err != nil {
+			return err
+		}
+		entries := make([]jsonTreeEntry, 0, len(tree.Children))
+		for _, c := range tree.Children {
+			entries = append(entries, jsonTreeEntry{
+				Type: object.TypeToString(c.Type),
+				Mode: jsonMode(c.FileMode()),
+				Name: string(c.Name),
+				Hash: hex.EncodeToString(c.Hash),
+			})
+		}
+		return printJSON(jsonObject{
+			Hash:    hex.EncodeToString(hash),
+			Type:    object.TypeToString(obj.Type),
+			Entries: &entries,
+		})
+	case object.TypeCommit:
+		commit, err := obj.ParseCommit()
+		if err != nil {
+			return err
+		}
+		c := newJSONCommit(hash, commit)
+		return printJSON(jsonObject{
+			Hash:   c.Hash,
+			Type:   object.TypeToString(obj.Type),
+			Commit: &c,
+		})
+	}
+	return fmt.Errorf("unknown type of object %x", hash)
+}
+
+// Check if file kept its place but changed mode
+func modeChanged(c *object.FileChange) bool {
+	return c.OldMode != 0 && c.NewMode != 0 && c.OldMode != c.NewMode
+}
+
+// Check if change is empty directory added or removed
+func isDirectory(c *object.FileChange) bool {
+	return c.OldMode == object.ModeDirectory || c.NewMode == object.ModeDirectory
+}
+
+// Close repository opened by interrupted command
+func (cli *CLI) Close() {
+	cli.close()
 }
 
 func (cli *CLI) Exit() {
-	fmt.Println("Closing database...")
-	cli.Storage.CloseStorage()
+	if cli.Storage != nil {
+		fmt.Println("Closing database...")
+		cli.close()
+	}
 	fmt.Println("Exit")
 }
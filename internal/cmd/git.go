@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"mymodule/internal/git"
+	"sort"
+	"strings"
+)
+
+func (cli *CLI) exportGit(args []string) error {
+	var dir string = ""
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "-h", "--help":
+			fmt.Printf("usage: export-git <dir>\n")
+			fmt.Printf("\n")
+			fmt.Printf("Writes history of all branches into git repository <dir>/.git.\n")
+			fmt.Printf("Repeated export converts only new commits.\n")
+			fmt.Printf("\n")
+			fmt.Printf("Available options\n")
+			fmt.Printf("  %-9s    show help (this message)\n", "-h --help")
+			return nil
+		default:
+			if dir == "" {
+				dir = arg
+			} else {
+				return fmt.Errorf("Unknown argument %s. Type \"export-git -h\" for help.", arg)
+			}
+		}
+	}
+	if dir == "" {
+		return errors.New("Directory is not specified. Type \"export-git -h\" for help.")
+	}
+
+	result, err := git.Export(cli.Storage, dir)
+	if err != nil {
+		return err
+	}
+	sort.Strings(result.Branches)
+	fmt.Printf("Exported %d new commits, %d objects written\n", result.Commits, result.Objects)
+	fmt.Printf("Branches: %s\n", strings.Join(result.Branches, ", "))
+	return nil
+}
+
+func (cli *CLI) importGit(args []string) error {
+	var path string = ""
+	var force bool = false
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "-h", "--help":
+			fmt.Printf("usage: import-git <path>\n")
+			fmt.Printf("   or: import-git -f <path>\n")
+			fmt.Printf("\n")
+			fmt.Printf("Imports history of all branches of local git repository.\n")
+			fmt.Printf("\n")
+			fmt.Printf("Available options\n")
+			fmt.Printf("  %-10s    show help (this message)\n", "-h --help")
+			fmt.Printf("  %-10s    overwrite existing branches pointing to other commits\n", "-f --force")
+			return nil
+		case "-f", "--force":
+			force = true
+		default:
+			if path == "" {
+				path = arg
+			} else {
+				return fmt.Errorf("Unknown argument %s. Type \"import-git -h\" for help.", arg)
+			}
+		}
+	}
+	if path == "" {
+		return errors.New("Path is not specified. Type \"import-git -h\" for help.")
+	}
+
+	result, err := git.Import(cli.Storage, path, force)
+	if err != nil {
+		return err
+	}
+	for _, w := range result.Warnings {
+		fmt.Printf("warning: %s\n", w)
+	}
+	fmt.Printf("Imported %d commits, %d new objects stored\n", result.Commits, result.Stored)
+	fmt.Printf("Branches: %s\n", strings.Join(result.Branches, ", "))
+	return nil
+}
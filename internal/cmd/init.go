@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"fmt"
+	"mymodule/internal/object"
+	"mymodule/internal/storage"
+	"path/filepath"
+	"strings"
+)
+
+func (cli *CLI) initRepository(args []string) error {
+	var hashAlgorithm string = object.HashSHA256
+	var dir string = ""
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		// options may be written as "--option=value"
+		name, value, hasValue := strings.Cut(arg, "=")
+		if !strings.HasPrefix(arg, "--") {
+			name, hasValue = arg, false
+		}
+		switch name {
+		case "-h", "--help":
+			fmt.Printf("usage: init [--hash=<algorithm>] [<directory>]\n")
+			fmt.Printf("\n")
+			fmt.Printf("Creates empty repository in directory, current one by default. Directory is\n")
+			fmt.Printf("created if needed. Hash algorithm of objects can't be changed later.\n")
+			fmt.Printf("\n")
+			fmt.Printf("Available options\n")
+			fmt.Printf("  %-10s    show help (this message)\n", "-h --help")
+			fmt.Printf("  %-10s    hash algorithm: %s\n", "--hash", strings.Join(object.HashAlgorithms(), ", "))
+			fmt.Printf("  %-10s    default - %s\n", "", object.HashSHA256)
+			return nil
+		case "--hash":
+			if !hasValue {
+				if i+1 >= len(args) {
+					return fmt.Errorf("Wrong usage of argument %s. Type \"init -h\" for help.", arg)
+				}
+				value = args[i+1]
+				i++
+			}
+			hashAlgorithm = value
+		default:
+			if dir != "" || strings.HasPrefix(arg, "-") {
+				return fmt.Errorf("Unknown argument %s. Type \"init -h\" for help.", arg)
+			}
+			dir = arg
+		}
+	}
+
+	// relative directory is taken from directory given by -C
+	path := cli.Dir
+	if dir != "" {
+		path = dir
+		if !filepath.IsAbs(dir) {
+			path = filepath.Join(cli.Dir, dir)
+		}
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	s, err := storage.InitStorage(path, hashAlgorithm)
+	if err != nil {
+		return err
+	}
+	s.CloseStorage()
+	fmt.Printf("Initialized empty repository in %s, objects hashed with %s\n", filepath.Join(path, storage.VCS_DIR), hashAlgorithm)
+	return nil
+}
@@ -0,0 +1,286 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"mymodule/internal/object"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// Option switching output of command to JSON
+const JSON_OPTION = "--json"
+
+// Number of unchanged lines around changes in diff hunks
+const HUNK_CONTEXT = 3
+
+// JSON output of commands. Every command prints one object on one line. Hashes are hex
+// strings, empty string for absent hash; times are RFC3339 strings; modes are octal strings.
+
+type jsonError struct {
+	Error string `json:"error"`
+}
+
+type jsonCommit struct {
+	Hash        string   `json:"hash"`
+	Tree        string   `json:"tree"`
+	Origin      string   `json:"origin"`
+	Merges      []string `json:"merges"`
+	Author      string   `json:"author"`
+	Time        string   `json:"time"`
+	Description string   `json:"description"`
+}
+
+type jsonCommitResult struct {
+	Hash   string `json:"hash"`
+	Branch string `json:"branch"`
+	Stored int    `json:"stored"`
+	Reused int    `json:"reused"`
+}
+
+type jsonBranch struct {
+	Name    string `json:"name"`
+	Hash    string `json:"hash"`
+	Current bool   `json:"current"`
+}
+
+type jsonBranches struct {
+	Current  string       `json:"current"`
+	Branches []jsonBranch `json:"branches"`
+}
+
+type jsonBranchLog struct {
+	Branch  string       `json:"branch"`
+	Commits []jsonCommit `json:"commits"`
+}
+
+type jsonTreeEntry struct {
+	Type string `json:"type"`
+	Mode string `json:"mode"`
+	Name string `json:"name"`
+	Hash string `json:"hash"`
+}
+
+type jsonObject struct {
+	Hash     string           `json:"hash"`
+	Type     string           `json:"type"`
+	Commit   *jsonCommit      `json:"commit,omitempty"`
+	Entries  *[]jsonTreeEntry `json:"entries,omitempty"`
+	Size     *int             `json:"size,omitempty"`
+	Encoding string           `json:"encoding,omitempty"` //"utf-8" or "base64" for content of blob
+	Content  *string          `json:"content,omitempty"`
+}
+
+type jsonHunk struct {
+	OldStart int      `json:"old_start"`
+	OldLines int      `json:"old_lines"`
+	NewStart int      `json:"new_start"`
+	NewLines int      `json:"new_lines"`
+	Lines    []string `json:"lines"` //Lines prefixed with " ", "-" or "+"
+}
+
+type jsonFileDiff struct {
+	Path       string     `json:"path"`
+	Status     string     `json:"status"`
+	OldMode    string     `json:"old_mode"` //Empty for added file
+	NewMode    string     `json:"new_mode"` //Empty for deleted file
+	Insertions int        `json:"insertions"`
+	Deletions  int        `json:"deletions"`
+	Hunks      []jsonHunk `json:"hunks"`
+}
+
+type jsonDiff struct {
+	From  string         `json:"from"` //Empty for working tree
+	To    string         `json:"to"`   //Empty for working tree
+	Files []jsonFileDiff `json:"files"`
+}
+
+type jsonFileStatus struct {
+	Path    string `json:"path"`
+	Status  string `json:"status"`
+	OldMode string `json:"old_mode"`
+	NewMode string `json:"new_mode"`
+}
+
+type jsonStatus struct {
+	Branch    string           `json:"branch"`
+	Head      string           `json:"head"`
+	Clean     bool             `json:"clean"`
+	Files     []jsonFileStatus `json:"files"`
+	Operation string           `json:"operation,omitempty"` //Operation in progress, like "cherry-pick"
+	Conflicts []string         `json:"conflicts,omitempty"` //Files it stopped on
+}
+
+// Print value as one line of JSON
+func printJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "%s\n", data)
+	return nil
+}
+
+// Octal mode as in git, empty if there is no file
+func jsonMode(mode uint32) string {
+	if mode == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%06o", mode)
+}
+
+func jsonTime(t int64) string {
+	return time.Unix(t, 0).Format(time.RFC3339)
+}
+
+func newJSONCommit(hash []byte, c *object.Commit) jsonCommit {
+	merges := make([]string, 0, len(c.Merges))
+	for _, m := range c.Merges {
+		merges = append(merges, hex.EncodeToString(m))
+	}
+	return jsonCommit{
+		Hash:        hex.EncodeToString(hash),
+		Tree:        hex.EncodeToString(c.Tree),
+		Origin:      hex.EncodeToString(c.Origin),
+		Merges:      merges,
+		Author:      string(c.Author),
+		Time:        jsonTime(c.Time),
+		Description: string(c.Description),
+	}
+}
+
+func newJSONBlob(hash []byte, data []byte) jsonObject {
+	size := len(data)
+	obj := jsonObject{
+		Hash:     hex.EncodeToString(hash),
+		Type:     object.TypeToString(object.TypeBlob),
+		Size:     &size,
+		Encoding: "utf-8",
+	}
+	content := string(data)
+	if !utf8.Valid(data) {
+		obj.Encoding = "base64"
+		content = base64.StdEncoding.EncodeToString(data)
+	}
+	obj.Content = &content
+	return obj
+}
+
+func newJSONFileDiff(c *object.FileChange) jsonFileDiff {
+	lines := diffLines(c.Changes)
+	file := jsonFileDiff{
+		Path:    string(c.FileName),
+		Status:  c.Status,
+		OldMode: jsonMode(c.OldMode),
+		NewMode: jsonMode(c.NewMode),
+		Hunks:   hunks(lines, HUNK_CONTEXT),
+	}
+	for _, l := range lines {
+		switch l.op {
+		case diffmatchpatch.DiffInsert:
+			file.Insertions++
+		case diffmatchpatch.DiffDelete:
+			file.Deletions++
+		}
+	}
+	return file
+}
+
+type diffLine struct {
+	op   diffmatchpatch.Operation
+	text string
+}
+
+// Split line based diff into separate lines without line ends
+func diffLines(diffs []diffmatchpatch.Diff) []diffLine {
+	lines := make([]diffLine, 0)
+	for _, d := range diffs {
+		for _, text := range strings.SplitAfter(d.Text, "\n") {
+			if text == "" {
+				continue
+			}
+			lines = append(lines, diffLine{d.Type, strings.TrimSuffix(text, "\n")})
+		}
+	}
+	return lines
+}
+
+// Group changed lines into hunks with context unchanged lines around them, as in
+// unified diff. Changes separated by at most 2*context unchanged lines share a hunk.
+func hunks(lines []diffLine, context int) []jsonHunk {
+	result := make([]jsonHunk, 0)
+	// numbers of old and new lines before line i
+	oldBefore := make([]int, len(lines)+1)
+	newBefore := make([]int, len(lines)+1)
+	for i, l := range lines {
+		oldBefore[i+1], newBefore[i+1] = oldBefore[i], newBefore[i]
+		if l.op != diffmatchpatch.DiffInsert {
+			oldBefore[i+1]++
+		}
+		if l.op != diffmatchpatch.DiffDelete {
+			newBefore[i+1]++
+		}
+	}
+
+	i := 0
+	for i < len(lines) {
+		for i < len(lines) && lines[i].op == diffmatchpatch.DiffEqual {
+			i++
+		}
+		if i == len(lines) {
+			break
+		}
+		start := max(i-context, 0)
+		end := i
+		for j := i; j < len(lines); {
+			if lines[j].op != diffmatchpatch.DiffEqual {
+				j++
+				end = j
+				continue
+			}
+			k := j
+			for k < len(lines) && lines[k].op == diffmatchpatch.DiffEqual {
+				k++
+			}
+			if k == len(lines) || k-j > 2*context {
+				break
+			}
+			j = k
+		}
+		stop := min(end+context, len(lines))
+
+		h := jsonHunk{
+			OldStart: oldBefore[start] + 1,
+			OldLines: oldBefore[stop] - oldBefore[start],
+			NewStart: newBefore[start] + 1,
+			NewLines: newBefore[stop] - newBefore[start],
+			Lines:    make([]string, 0, stop-start),
+		}
+		// empty range starts at line before it
+		if h.OldLines == 0 {
+			h.OldStart--
+		}
+		if h.NewLines == 0 {
+			h.NewStart--
+		}
+		for _, l := range lines[start:stop] {
+			prefix := " "
+			switch l.op {
+			case diffmatchpatch.DiffInsert:
+				prefix = "+"
+			case diffmatchpatch.DiffDelete:
+				prefix = "-"
+			}
+			h.Lines = append(h.Lines, prefix+l.text)
+		}
+		result = append(result, h)
+		i = stop
+	}
+	return result
+}
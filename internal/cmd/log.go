@@ -0,0 +1,219 @@
+package cmd
+
+import (
+	"fmt"
+	"mymodule/internal/history"
+	"mymodule/internal/storage"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// Number of hex digits of abbreviated hash in log
+const SHORT_HASH = 7
+
+func (cli *CLI) log(args []string) error {
+	var all bool = false
+	var oneline bool = false
+	var graph bool = true
+	filter := &history.Filter{}
+	revs := make([]string, 0)
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "-h", "--help":
+			fmt.Printf("usage: log [<revision>...]\n")
+			fmt.Printf("   or: log <revision1>..<revision2>\n")
+			fmt.Printf("   or: log --all [--oneline] [--no-graph] [-c <count>] [--skip <count>]\n")
+			fmt.Printf("   or: log --author <pattern> --grep <pattern> --since <date> --until <date>\n")
+			fmt.Printf("\n")
+			fmt.Printf("Shows commits reachable from revisions, HEAD by default, children before\n")
+			fmt.Printf("parents, with graph of branches and merges. Commits are marked with names\n")
+			fmt.Printf("of branches pointing at them. Range A..B shows commits reachable\n")
+			fmt.Printf("from B but not from A, missing side means HEAD.\n")
+			fmt.Printf("\n")
+			fmt.Printf("Date is \"2006-01-02\", \"2006-01-02 15:04:05\", \"02.01.2006\", RFC3339, \"today\",\n")
+			fmt.Printf("\"yesterday\" or relative, like \"3 days ago\" or \"2.weeks\".\n")
+			fmt.Printf("\n")
+			fmt.Printf("Available options\n")
+			fmt.Printf("  %-12s    show help (this message)\n", "-h --help")
+			fmt.Printf("  %-12s    show all branches\n", "-a --all")
+			fmt.Printf("  %-12s    show one line per commit\n", "--oneline")
+			fmt.Printf("  %-12s    do not draw graph\n", "--no-graph")
+			fmt.Printf("  %-12s    set commit's limit\n", "-c --count")
+			fmt.Printf("  %-12s    leave out first n matching commits\n", "--skip")
+			fmt.Printf("  %-12s    only commits with author matching regular expression\n", "--author")
+			fmt.Printf("  %-12s    only commits with description matching regular expression\n", "--grep")
+			fmt.Printf("  %-12s    only commits made at date or later\n", "--since")
+			fmt.Printf("  %-12s    only commits made at date or earlier\n", "--until")
+			return nil
+		case "-a", "--all":
+			all = true
+		case "--oneline":
+			oneline = true
+		case "--no-graph":
+			graph = false
+		case "-c", "--count", "--skip", "--author", "--grep", "--since", "--until":
+			if i+1 >= len(args) {
+				return fmt.Errorf("Wrong usage of argument %s. Type \"log -h\" for help.", arg)
+			}
+			err := setFilter(filter, arg, args[i+1])
+			if err != nil {
+				return err
+			}
+			i++
+		default:
+			if strings.HasPrefix(arg, "-") {
+				return fmt.Errorf("Unknown argument %s. Type \"log -h\" for help.", arg)
+			}
+			revs = append(revs, arg)
+		}
+	}
+
+	heads := make([][]byte, 0)
+	if all {
+		for _, branch := range cli.Storage.GetBranches() {
+			heads = append(heads, cli.Storage.Refs[branch])
+		}
+	}
+	if !all && len(revs) == 0 {
+		revs = append(revs, storage.HEAD)
+	}
+	for _, rev := range revs {
+		from, to, isRange := strings.Cut(rev, "..")
+		if !isRange {
+			from, to = "", rev
+		} else if from == "" {
+			from = storage.HEAD
+		}
+		if to == "" {
+			to = storage.HEAD
+		}
+		hash, err := cli.Storage.ResolveRevision(to)
+		if err != nil {
+			return err
+		}
+		heads = append(heads, hash)
+		if isRange {
+			hash, err := cli.Storage.ResolveRevision(from)
+			if err != nil {
+				return err
+			}
+			filter.Exclude = append(filter.Exclude, hash)
+		}
+	}
+
+	filter.Graph = graph
+	commits, err := history.Walk(cli.Storage, heads, filter)
+	if err != nil {
+		return err
+	}
+	decorations := cli.decorations()
+	g := &history.Graph{}
+	for i, entry := range commits {
+		commitData := entry.CommitData
+		row := &history.Row{}
+		if graph {
+			row = g.Commit(commitData.Hash, entry.Parents)
+		}
+		for _, line := range row.Before {
+			fmt.Println(line)
+		}
+		lines := logLines(commitData, decorations[string(commitData.Hash)], oneline)
+		if !oneline && i != len(commits)-1 {
+			lines = append(lines, "")
+		}
+		for j, line := range lines {
+			prefix := row.Padding
+			if j == 0 {
+				prefix = row.Line
+			}
+			if prefix != "" {
+				prefix += " "
+			}
+			fmt.Println(strings.TrimRight(prefix+line, " "))
+		}
+		for _, line := range row.After {
+			fmt.Println(line)
+		}
+	}
+	return nil
+}
+
+// Set condition of filter given by option
+func setFilter(filter *history.Filter, option string, value string) error {
+	var err error
+	switch option {
+	case "-c", "--count", "--skip":
+		var n int
+		n, err = strconv.Atoi(value)
+		if err == nil && n < 0 {
+			err = fmt.Errorf("negative number %d", n)
+		}
+		if option == "--skip" {
+			filter.Skip = n
+		} else {
+			filter.Count = n
+		}
+	case "--author":
+		filter.Author, err = regexp.Compile(value)
+	case "--grep":
+		filter.Grep, err = regexp.Compile(value)
+	case "--since":
+		filter.Since, err = history.ParseDate(value, time.Now())
+	case "--until":
+		filter.Until, err = history.ParseDate(value, time.Now())
+	}
+	if err != nil {
+		return fmt.Errorf("Wrong value of argument %s: %w", option, err)
+	}
+	return nil
+}
+
+// Text of commit in log
+func logLines(commitData *storage.CommitData, decoration []string, oneline bool) []string {
+	commit := commitData.Commit
+	refs := ""
+	if len(decoration) > 0 {
+		refs = " " + color.GreenString("(%s)", strings.Join(decoration, ", "))
+	}
+	description := strings.Split(strings.TrimRight(string(commit.Description), "\n"), "\n")
+	if oneline {
+		hash := fmt.Sprintf("%x", commitData.Hash)[:SHORT_HASH]
+		return []string{color.YellowString("%s", hash) + refs + " " + description[0]}
+	}
+
+	lines := []string{color.YellowString("commit %x", commitData.Hash) + refs}
+	if len(commit.Merges) > 0 {
+		parents := make([]string, 0)
+		for _, p := range history.Parents(commitData) {
+			parents = append(parents, fmt.Sprintf("%x", p)[:SHORT_HASH])
+		}
+		lines = append(lines, "Merge:  "+strings.Join(parents, " "))
+	}
+	lines = append(lines, "Author: "+string(commit.Author))
+	lines = append(lines, "Date:   "+time.Unix(commit.Time, 0).Format("02.01.2006 15:04:05"))
+	lines = append(lines, "")
+	for _, line := range description {
+		lines = append(lines, "    "+line)
+	}
+	return lines
+}
+
+// Names of branches by commit hash, current branch is marked with HEAD
+func (cli *CLI) decorations() map[string][]string {
+	decorations := make(map[string][]string)
+	head := cli.Storage.Refs[cli.Storage.Branch]
+	decorations[string(head)] = append(decorations[string(head)], storage.HEAD+" -> "+cli.Storage.Branch)
+	for _, branch := range cli.Storage.GetBranches() {
+		if branch != cli.Storage.Branch {
+			hash := cli.Storage.Refs[branch]
+			decorations[string(hash)] = append(decorations[string(hash)], branch)
+		}
+	}
+	return decorations
+}
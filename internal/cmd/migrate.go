@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+func (cli *CLI) migrate(args []string) error {
+	var output string = ""
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "-h", "--help":
+			fmt.Printf("usage: migrate\n")
+			fmt.Printf("   or: migrate -o <file>\n")
+			fmt.Printf("\n")
+			fmt.Printf("Rewrites objects stored by old versions into current encoding.\n")
+			fmt.Printf("Old hashes still resolve to rewritten objects.\n")
+			fmt.Printf("\n")
+			fmt.Printf("Available options\n")
+			fmt.Printf("  %-12s    show help (this message)\n", "-h --help")
+			fmt.Printf("  %-12s    write mapping \"<old> <new>\" to file\n", "-o --output")
+			fmt.Printf("  %-12s    default - print mapping\n", "")
+			return nil
+		case "-o", "--output":
+			if i+1 >= len(args) {
+				return fmt.Errorf("Wrong usage of argument %s. Type \"migrate -h\" for help.", arg)
+			}
+			output = args[i+1]
+			i++
+		default:
+			return fmt.Errorf("Unknown argument %s. Type \"migrate -h\" for help.", arg)
+		}
+	}
+
+	mapping, err := cli.Storage.Migrate()
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(mapping))
+	for k := range mapping {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	out := os.Stdout
+	if output != "" {
+		out, err = os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+	}
+	for _, k := range keys {
+		fmt.Fprintf(out, "%s %x\n", k, mapping[k])
+	}
+	fmt.Printf("Migrated %d objects\n", len(mapping))
+	return nil
+}
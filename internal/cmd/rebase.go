@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mymodule/internal/sequencer"
+	"strings"
+)
+
+func (cli *CLI) rebase(args []string) error {
+	var action string = ""
+	var upstream string = ""
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "-h", "--help":
+			fmt.Printf("usage: rebase <upstream>\n")
+			fmt.Printf("   or: rebase --continue | --skip | --abort\n")
+			fmt.Printf("\n")
+			fmt.Printf("Replays commits of current branch that are not in upstream on top of it, oldest\n")
+			fmt.Printf("first, keeping their authors and descriptions; merge commits are left out.\n")
+			fmt.Printf("Branch behind upstream is just moved to it. Working tree must be clean.\n")
+			fmt.Printf("\n")
+			fmt.Printf("If changes of commit conflict, files get both versions between markers and\n")
+			fmt.Printf("rebase stops: edit them and continue, skip the commit or abort to return to\n")
+			fmt.Printf("the state before rebase. State of rebase is kept in repository.\n")
+			fmt.Printf("\n")
+			fmt.Printf("Available options\n")
+			fmt.Printf("  %-10s    show help (this message)\n", "-h --help")
+			fmt.Printf("  %-10s    commit resolved files and replay the rest of commits\n", "--continue")
+			fmt.Printf("  %-10s    drop commit rebase stopped on and replay the rest\n", "--skip")
+			fmt.Printf("  %-10s    cancel rebase, changes of files are lost\n", "--abort")
+			return nil
+		case "--continue", "--skip", "--abort":
+			if action != "" {
+				return fmt.Errorf("Unknown argument %s. Type \"rebase -h\" for help.", arg)
+			}
+			action = arg
+		default:
+			if strings.HasPrefix(arg, "-") || upstream != "" {
+				return fmt.Errorf("Unknown argument %s. Type \"rebase -h\" for help.", arg)
+			}
+			upstream = arg
+		}
+	}
+	if action != "" && upstream != "" {
+		return fmt.Errorf("Unknown argument %s. Type \"rebase -h\" for help.", upstream)
+	}
+
+	var steps []*sequencer.Step
+	var err error
+	head := cli.Storage.Refs[cli.Storage.Branch]
+	switch action {
+	case "--abort":
+		return cli.abortSequence(sequencer.REBASE)
+	case "--continue":
+		steps, err = sequencer.Continue(cli.Storage, sequencer.REBASE)
+	case "--skip":
+		steps, err = sequencer.Skip(cli.Storage, sequencer.REBASE)
+		if steps != nil {
+			fmt.Printf("Commit skipped\n")
+		}
+	default:
+		if upstream == "" {
+			return errors.New("Upstream is not specified. Type \"rebase -h\" for help.")
+		}
+		var hash []byte
+		hash, err = cli.Storage.ResolveRevision(upstream)
+		if err != nil {
+			return err
+		}
+		steps, err = sequencer.Rebase(cli.Storage, hash)
+		if err == nil && bytes.Equal(head, cli.Storage.Refs[cli.Storage.Branch]) {
+			fmt.Printf("Current branch %s is up to date\n", cli.Storage.Branch)
+			return nil
+		}
+	}
+	printSteps(steps)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Branch %s rebased, now at %x\n", cli.Storage.Branch, cli.Storage.Refs[cli.Storage.Branch])
+	return nil
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"fmt"
+	"mymodule/internal/storage"
+	"time"
+)
+
+func (cli *CLI) reflog(args []string) error {
+	var ref string = ""
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "-h", "--help":
+			fmt.Printf("usage: reflog\n")
+			fmt.Printf("   or: reflog <ref>\n")
+			fmt.Printf("\n")
+			fmt.Printf("Shows where ref pointed to before, newest first. Default ref - HEAD.\n")
+			fmt.Printf("Entry n can be used as revision <ref>@{n}.\n")
+			fmt.Printf("\n")
+			fmt.Printf("Available options\n")
+			fmt.Printf("  %-9s    show help (this message)\n", "-h --help")
+			return nil
+		default:
+			if ref == "" {
+				ref = arg
+			} else {
+				return fmt.Errorf("Unknown argument %s. Type \"reflog -h\" for help.", arg)
+			}
+		}
+	}
+	if ref == "" {
+		ref = storage.HEAD
+	}
+	if ref != storage.HEAD && cli.Storage.Refs[ref] == nil {
+		return fmt.Errorf("branch \"%s\" does not exist", ref)
+	}
+	entries, err := cli.Storage.GetReflog(ref)
+	if err != nil {
+		return err
+	}
+	for i, entry := range entries {
+		fmt.Printf(
+			"%.4x  %s  %s  %-10s  %s\n",
+			entry.New,
+			fmt.Sprintf("%s@{%d}", ref, i),
+			time.Unix(entry.Time, 0).Format("02.01.2006 15:04:05"),
+			entry.Author,
+			entry.Operation,
+		)
+	}
+	return nil
+}
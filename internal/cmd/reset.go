@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"fmt"
+	"mymodule/internal/storage"
+	"strings"
+)
+
+func (cli *CLI) reset(args []string) error {
+	var mode string = storage.ResetMixed
+	var force bool = false
+	var rev string = ""
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "-h", "--help":
+			fmt.Printf("usage: reset [--soft | --mixed | --hard [-f]] [<revision>]\n")
+			fmt.Printf("\n")
+			fmt.Printf("Moves current branch to commit, HEAD by default. There is no index, so soft\n")
+			fmt.Printf("and mixed reset leave files as they are and only move branch; hard reset also\n")
+			fmt.Printf("replaces files of working tree with files of commit, losing their changes.\n")
+			fmt.Printf("Hard reset refuses to remove or overwrite untracked files, ones missing in\n")
+			fmt.Printf("current commit, unless forced.\n")
+			fmt.Printf("\n")
+			fmt.Printf("Available options\n")
+			fmt.Printf("  %-10s    show help (this message)\n", "-h --help")
+			fmt.Printf("  %-10s    move branch only\n", "--soft")
+			fmt.Printf("  %-10s    the same as --soft, default\n", "--mixed")
+			fmt.Printf("  %-10s    move branch and replace files of working tree\n", "--hard")
+			fmt.Printf("  %-10s    remove or overwrite untracked files on hard reset\n", "-f --force")
+			return nil
+		case "--soft", "--mixed", "--hard":
+			mode = strings.TrimPrefix(arg, "--")
+		case "-f", "--force":
+			force = true
+		default:
+			if strings.HasPrefix(arg, "-") || rev != "" {
+				return fmt.Errorf("Unknown argument %s. Type \"reset -h\" for help.", arg)
+			}
+			rev = arg
+		}
+	}
+	if force && mode != storage.ResetHard {
+		return fmt.Errorf("Wrong usage of argument --force, it is only used with --hard. Type \"reset -h\" for help.")
+	}
+	if rev == "" {
+		rev = storage.HEAD
+	}
+
+	hash, err := cli.Storage.ResolveRevision(rev)
+	if err != nil {
+		return err
+	}
+	err = cli.Storage.Reset(hash, mode, force)
+	if err != nil {
+		return err
+	}
+	commitData, err := cli.Storage.GetCommit(hash)
+	if err != nil {
+		return err
+	}
+	summary, _, _ := strings.Cut(string(commitData.Commit.Description), "\n")
+	fmt.Printf("Branch %s is now at %s %s\n", cli.Storage.Branch, fmt.Sprintf("%x", hash)[:SHORT_HASH], summary)
+	return nil
+}
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"mymodule/internal/sequencer"
+	"os/user"
+	"strings"
+)
+
+func (cli *CLI) revert(args []string) error {
+	var action string = ""
+	var author string = ""
+	revs := make([]string, 0)
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "-h", "--help":
+			fmt.Printf("usage: revert [-a <author>] <revision>...\n")
+			fmt.Printf("   or: revert --continue\n")
+			fmt.Printf("   or: revert --abort\n")
+			fmt.Printf("\n")
+			fmt.Printf("Undoes changes made by commits, each relative to its first parent, on current\n")
+			fmt.Printf("branch. Every commit gets a new one described as \"Revert \"<description>\"\"\n")
+			fmt.Printf("with hash of reverted commit. Working tree must be clean.\n")
+			fmt.Printf("\n")
+			fmt.Printf("If files were changed after reverted commit and changes conflict, files get\n")
+			fmt.Printf("both versions between markers and revert stops: edit them and continue, or\n")
+			fmt.Printf("abort to return to the state before revert.\n")
+			fmt.Printf("\n")
+			fmt.Printf("Available options\n")
+			fmt.Printf("  %-11s    show help (this message)\n", "-h --help")
+			fmt.Printf("  %-11s    set author of new commits, default - current username\n", "-a --author")
+			fmt.Printf("  %-11s    commit resolved files and revert the rest of commits\n", "--continue")
+			fmt.Printf("  %-11s    cancel revert, changes of files are lost\n", "--abort")
+			return nil
+		case "-a", "--author":
+			if i+1 >= len(args) {
+				return fmt.Errorf("Wrong usage of argument %s. Type \"revert -h\" for help.", arg)
+			}
+			author = args[i+1]
+			i++
+		case "--continue", "--abort":
+			if action != "" {
+				return fmt.Errorf("Unknown argument %s. Type \"revert -h\" for help.", arg)
+			}
+			action = arg
+		default:
+			if strings.HasPrefix(arg, "-") {
+				return fmt.Errorf("Unknown argument %s. Type \"revert -h\" for help.", arg)
+			}
+			revs = append(revs, arg)
+		}
+	}
+	if action != "" && len(revs) > 0 {
+		return fmt.Errorf("Unknown argument %s. Type \"revert -h\" for help.", revs[0])
+	}
+
+	var steps []*sequencer.Step
+	var err error
+	switch action {
+	case "--abort":
+		return cli.abortSequence(sequencer.REVERT)
+	case "--continue":
+		steps, err = sequencer.Continue(cli.Storage, sequencer.REVERT)
+	default:
+		if len(revs) == 0 {
+			return errors.New("Revision is not specified. Type \"revert -h\" for help.")
+		}
+		if author == "" {
+			user, err := user.Current()
+			if err != nil {
+				return errors.New("User is not specified. Type \"revert -h\" for help.")
+			}
+			author = user.Username
+		}
+		var commits [][]byte
+		commits, err = cli.resolveCommits(revs)
+		if err != nil {
+			return err
+		}
+		steps, err = sequencer.Revert(cli.Storage, commits, author)
+	}
+	printSteps(steps)
+	return err
+}
@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"mymodule/internal/sequencer"
+	"mymodule/internal/storage"
+	"os/user"
+	"strconv"
+	"strings"
+)
+
+func (cli *CLI) stash(args []string) error {
+	var action string = "push"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		action, args = args[0], args[1:]
+	}
+	switch action {
+	case "push", "list", "show", "apply", "pop", "drop":
+	default:
+		return fmt.Errorf("Unknown argument %s. Type \"stash -h\" for help.", action)
+	}
+	var message string = ""
+	var verbose bool = false
+	var entry string = ""
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "-h" || arg == "--help":
+			fmt.Printf("usage: stash [push] [-m <message>]\n")
+			fmt.Printf("   or: stash list\n")
+			fmt.Printf("   or: stash show [-v] [<stash>]\n")
+			fmt.Printf("   or: stash apply | pop | drop [<stash>]\n")
+			fmt.Printf("\n")
+			fmt.Printf("Push saves changes of working tree as stash commit on top of stash and makes\n")
+			fmt.Printf("working tree match current commit again. Apply brings saved changes back onto\n")
+			fmt.Printf("clean working tree, merging files changed since then by lines; pop also\n")
+			fmt.Printf("drops stash unless there were conflicts. Stash is given as stash@{n} or n,\n")
+			fmt.Printf("the newest one stash@{0} by default.\n")
+			fmt.Printf("\n")
+			fmt.Printf("Available options\n")
+			fmt.Printf("  %-12s    show help (this message)\n", "-h --help")
+			fmt.Printf("  %-12s    set description of stash, default - \"WIP on <branch>: ...\"\n", "-m --message")
+			fmt.Printf("  %-12s    show whole changes of stash\n", "-v --verbose")
+			return nil
+		case action == "push" && (arg == "-m" || arg == "--message"):
+			if i+1 >= len(args) {
+				return fmt.Errorf("Wrong usage of argument %s. Type \"stash -h\" for help.", arg)
+			}
+			message = args[i+1]
+			i++
+		case action == "show" && (arg == "-v" || arg == "--verbose"):
+			verbose = true
+		case entry == "" && !strings.HasPrefix(arg, "-") && action != "push" && action != "list":
+			entry = arg
+		default:
+			return fmt.Errorf("Unknown argument %s. Type \"stash -h\" for help.", arg)
+		}
+	}
+	n, err := parseStashEntry(entry)
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case "push":
+		return cli.pushStash(message)
+	case "list":
+		stash, err := cli.Storage.GetStash()
+		if err != nil {
+			return err
+		}
+		for i, hash := range stash {
+			commitData, err := cli.Storage.GetCommit(hash)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%s@{%d}: %s\n", storage.STASH, i, string(commitData.Commit.Description))
+		}
+		return nil
+	case "show":
+		hash, err := cli.Storage.StashEntry(n)
+		if err != nil {
+			return err
+		}
+		commitData, err := cli.Storage.GetCommit(hash)
+		if err != nil {
+			return err
+		}
+		changes, err := cli.Storage.DiffsBetweenCommits(commitData.Commit.Origin, hash)
+		if err != nil {
+			return err
+		}
+		printChanges(changes, verbose)
+		return nil
+	case "apply", "pop":
+		hash, err := cli.Storage.StashEntry(n)
+		if err != nil {
+			return err
+		}
+		conflicts, err := sequencer.ApplyStash(cli.Storage, hash)
+		if err != nil {
+			return err
+		}
+		if len(conflicts) > 0 {
+			return fmt.Errorf("%s@{%d} applied with conflicts in: %s\nresolve them by hand, stash is kept",
+				storage.STASH, n, strings.Join(conflicts, ", "))
+		}
+		fmt.Printf("Applied %s@{%d}\n", storage.STASH, n)
+		if action == "apply" {
+			return nil
+		}
+		return cli.dropStash(n)
+	case "drop":
+		return cli.dropStash(n)
+	}
+	return nil
+}
+
+// Save working tree as stash
+func (cli *CLI) pushStash(message string) error {
+	user, err := user.Current()
+	if err != nil {
+		return errors.New("User is not specified. Type \"stash -h\" for help.")
+	}
+	if message == "" {
+		head := cli.Storage.Refs[cli.Storage.Branch]
+		commitData, err := cli.Storage.GetCommit(head)
+		if err != nil {
+			return err
+		}
+		summary, _, _ := strings.Cut(string(commitData.Commit.Description), "\n")
+		message = fmt.Sprintf("WIP on %s: %s %s", cli.Storage.Branch, fmt.Sprintf("%x", head)[:SHORT_HASH], summary)
+	}
+	_, err = cli.Storage.PushStash(user.Username, message)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Saved working tree as %s@{0}: %s\n", storage.STASH, message)
+	return nil
+}
+
+func (cli *CLI) dropStash(n int) error {
+	hash, err := cli.Storage.DropStash(n)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Dropped %s@{%d} (%x)\n", storage.STASH, n, hash)
+	return nil
+}
+
+// Number of stash given as stash@{n} or n, 0 if empty
+func parseStashEntry(entry string) (int, error) {
+	if entry == "" {
+		return 0, nil
+	}
+	value := strings.TrimSuffix(strings.TrimPrefix(entry, storage.STASH+"@{"), "}")
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("unknown stash \"%s\"", entry)
+	}
+	return n, nil
+}
@@ -0,0 +1,267 @@
+package git
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"mymodule/internal/object"
+	"mymodule/internal/storage"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// File in git directory with mapping of our hashes to git object ids
+const EXPORT_MAP_FILE = "vcs-export-map"
+
+// Result of export
+type ExportResult struct {
+	Commits  int //Number of newly converted commits
+	Objects  int //Number of git objects written
+	Branches []string
+}
+
+// Conversion of repository objects into git objects
+type exporter struct {
+	s       *storage.Storage
+	gitDir  string
+	mapping map[string][]byte //Hex hash of our object to git object id
+	added   map[string][]byte //Part of mapping converted by this export
+	result  *ExportResult
+}
+
+// Export every commit reachable from branches into git repository in dir, creating it if
+// needed. Branches are written as refs/heads. Conversion is incremental: objects already
+// listed in mapping file of previous export are not converted again. Branch names become
+// paths of ref files, so nothing is exported if any of them is not a valid git ref name.
+func Export(s *storage.Storage, dir string) (*ExportResult, error) {
+	for _, branch := range s.GetBranches() {
+		if err := storage.CheckBranchName(branch); err != nil {
+			return nil, fmt.Errorf("can't export branch: %w", err)
+		}
+	}
+	gitDir := filepath.Join(dir, ".git")
+	err := initGitDir(gitDir)
+	if err != nil {
+		return nil, err
+	}
+	e := &exporter{
+		s:       s,
+		gitDir:  gitDir,
+		mapping: make(map[string][]byte),
+		added:   make(map[string][]byte),
+		result:  &ExportResult{Branches: make([]string, 0)},
+	}
+	err = e.loadMapping()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, branch := range s.GetBranches() {
+		sha, err := e.commit(s.Refs[branch])
+		if err != nil {
+			e.saveMapping()
+			return nil, err
+		}
+		err = writeRef(gitDir, "refs/heads/"+branch, sha)
+		if err != nil {
+			e.saveMapping()
+			return nil, err
+		}
+		e.result.Branches = append(e.result.Branches, branch)
+	}
+	err = e.saveMapping()
+	if err != nil {
+		return nil, err
+	}
+	err = os.WriteFile(filepath.Join(gitDir, "HEAD"), []byte("ref: refs/heads/"+s.Branch+"\n"), 0644)
+	if err != nil {
+		return nil, err
+	}
+	return e.result, nil
+}
+
+// Create minimal git directory layout
+func initGitDir(gitDir string) error {
+	for _, d := range []string{"objects", "refs/heads", "refs/tags"} {
+		err := os.MkdirAll(filepath.Join(gitDir, d), 0755)
+		if err != nil {
+			return err
+		}
+	}
+	config := filepath.Join(gitDir, "config")
+	if _, err := os.Stat(config); os.IsNotExist(err) {
+		err := os.WriteFile(config, []byte("[core]\n\trepositoryformatversion = 0\n\tfilemode = true\n\tbare = false\n"), 0644)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Write ref file, creating directories for branch names with "/"
+func writeRef(gitDir string, ref string, sha []byte) error {
+	path := filepath.Join(gitDir, ref)
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(fmt.Sprintf("%x\n", sha)), 0644)
+}
+
+// Convert commit with its history, returns git object id
+func (e *exporter) commit(hash []byte) ([]byte, error) {
+	if sha, ok := e.mapping[hex.EncodeToString(hash)]; ok {
+		return sha, nil
+	}
+	commitData, err := e.s.GetCommit(hash)
+	if err != nil {
+		return nil, err
+	}
+	c := commitData.Commit
+	gc := &Commit{
+		Author:  Ident(c.Author),
+		Time:    c.Time,
+		Message: c.Description,
+	}
+	// git tools expect message to end with newline
+	if !strings.HasSuffix(string(gc.Message), "\n") {
+		gc.Message = append(append([]byte{}, gc.Message...), '\n')
+	}
+	if len(c.Origin) != 0 {
+		parent, err := e.commit(c.Origin)
+		if err != nil {
+			return nil, err
+		}
+		gc.Parents = append(gc.Parents, parent)
+	}
+	for _, m := range c.Merges {
+		parent, err := e.commit(m)
+		if err != nil {
+			return nil, err
+		}
+		gc.Parents = append(gc.Parents, parent)
+	}
+	gc.Tree, err = e.tree(c.Tree)
+	if err != nil {
+		return nil, err
+	}
+	sha, err := e.write(hash, TypeCommit, EncodeCommit(gc))
+	if err != nil {
+		return nil, err
+	}
+	e.result.Commits++
+	return sha, nil
+}
+
+// Convert tree with its children, returns git object id
+func (e *exporter) tree(hash []byte) ([]byte, error) {
+	if sha, ok := e.mapping[hex.EncodeToString(hash)]; ok {
+		return sha, nil
+	}
+	obj, err := e.s.GetObject(hash)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := obj.ParseTree()
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]TreeEntry, 0, len(tree.Children))
+	for _, c := range tree.Children {
+		var sha []byte
+		// modes of tree entries are git modes
+		mode := fmt.Sprintf("%o", c.FileMode())
+		switch c.Type {
+		case object.TypeTree:
+			sha, err = e.tree(c.Hash)
+		case object.TypeBlob:
+			sha, err = e.blob(c.Hash)
+		default:
+			err = fmt.Errorf("unexpected %s in tree %x", object.TypeToString(c.Type), hash)
+		}
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, TreeEntry{mode, c.Name, sha})
+	}
+	return e.write(hash, TypeTree, EncodeTree(entries))
+}
+
+// Convert blob, returns git object id
+func (e *exporter) blob(hash []byte) ([]byte, error) {
+	if sha, ok := e.mapping[hex.EncodeToString(hash)]; ok {
+		return sha, nil
+	}
+	obj, err := e.s.GetObject(hash)
+	if err != nil {
+		return nil, err
+	}
+	blob, err := obj.ParseBlob()
+	if err != nil {
+		return nil, err
+	}
+	return e.write(hash, TypeBlob, blob.Data)
+}
+
+// Write git object converted from our object
+func (e *exporter) write(hash []byte, t string, data []byte) ([]byte, error) {
+	sha, written, err := WriteObject(e.gitDir, t, data)
+	if err != nil {
+		return nil, err
+	}
+	if written {
+		e.result.Objects++
+	}
+	key := hex.EncodeToString(hash)
+	e.mapping[key] = sha
+	e.added[key] = sha
+	return sha, nil
+}
+
+// Load mapping of previous exports
+func (e *exporter) loadMapping() error {
+	f, err := os.Open(filepath.Join(e.gitDir, EXPORT_MAP_FILE))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		ours, theirs, found := strings.Cut(scanner.Text(), " ")
+		if !found {
+			continue
+		}
+		sha, err := hex.DecodeString(theirs)
+		if err != nil {
+			continue
+		}
+		e.mapping[ours] = sha
+	}
+	return scanner.Err()
+}
+
+// Append objects converted by this export to mapping file
+func (e *exporter) saveMapping() error {
+	if len(e.added) == 0 {
+		return nil
+	}
+	f, err := os.OpenFile(filepath.Join(e.gitDir, EXPORT_MAP_FILE), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for ours, sha := range e.added {
+		fmt.Fprintf(w, "%s %x\n", ours, sha)
+	}
+	err = w.Flush()
+	closeErr := f.Close()
+	if err != nil {
+		return err
+	}
+	e.added = make(map[string][]byte)
+	return closeErr
+}
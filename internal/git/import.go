@@ -0,0 +1,282 @@
+package git
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"mymodule/internal/object"
+	"mymodule/internal/storage"
+	"sort"
+	"strings"
+)
+
+// Number of converted objects kept in memory before writing them to storage
+const importBatchSize = 10000
+
+// Result of import
+type ImportResult struct {
+	Commits  int //Number of converted commits
+	Stored   int //Number of new objects written to storage
+	Branches []string
+	Warnings []string //Entries that could not be imported faithfully
+}
+
+// Conversion of git objects into repository objects
+type importer struct {
+	repo    *Repository
+	s       *storage.Storage
+	mapping map[string][]byte         //Hex git object id to hash of our object
+	objects map[string]*object.Object //Converted objects not yet written, by hex hash
+	warned  map[string]bool
+	result  *ImportResult
+}
+
+// Import history of all branches of local git repository in path. Every branch becomes
+// branch with the same name; existing branches pointing elsewhere are overwritten only
+// with force or when they are at the empty initial commit. Current branch is moved only
+// with clean working tree, its files are then written. Tags, other refs, branches with invalid names and tree entries that can't
+// be represented or have names invalid in working tree are reported in warnings.
+func Import(s *storage.Storage, path string, force bool) (*ImportResult, error) {
+	repo, err := OpenRepository(path)
+	if err != nil {
+		return nil, err
+	}
+	refs, err := repo.Refs()
+	if err != nil {
+		return nil, err
+	}
+	im := &importer{
+		repo:    repo,
+		s:       s,
+		mapping: make(map[string][]byte),
+		objects: make(map[string]*object.Object),
+		warned:  make(map[string]bool),
+		result: &ImportResult{
+			Branches: make([]string, 0),
+			Warnings: make([]string, 0),
+		},
+	}
+
+	names := make([]string, 0, len(refs))
+	for name := range refs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	updates := make(map[string][]byte)
+	var fs *storage.FileSystem
+	for _, name := range names {
+		branch, found := strings.CutPrefix(name, "refs/heads/")
+		if !found {
+			im.warn("ref %s skipped: only branches are supported", name)
+			continue
+		}
+		if err := storage.CheckBranchName(branch); err != nil {
+			im.warn("ref %s skipped: %v", name, err)
+			continue
+		}
+		hash, err := im.commit(refs[name])
+		if err != nil {
+			return nil, fmt.Errorf("branch %s: %w", branch, err)
+		}
+		current, ok := s.Refs[branch]
+		if ok && !bytes.Equal(current, hash) && !force {
+			// branch of new repository still at its empty initial commit is replaced
+			initial, err := s.IsInitialCommit(current)
+			if err != nil {
+				return nil, err
+			}
+			if !initial {
+				im.warn("branch %s skipped: it already exists and points to another commit", branch)
+				continue
+			}
+		}
+		if branch == s.Branch && !bytes.Equal(current, hash) {
+			fs, err = s.MovableWorkTree()
+			if err != nil {
+				im.warn("branch %s skipped: it is checked out and %v", branch, err)
+				continue
+			}
+		}
+		updates[branch] = hash
+		im.result.Branches = append(im.result.Branches, branch)
+	}
+	err = im.flush()
+	if err != nil {
+		return nil, err
+	}
+	err = s.UpdateBranches(updates, "import-git: "+path)
+	if err != nil {
+		return nil, err
+	}
+	if fs != nil {
+		err = s.WriteBranchFiles(fs, s.Branch)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return im.result, nil
+}
+
+// Report entry once, even if it appears in many trees
+func (im *importer) warn(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	if !im.warned[msg] {
+		im.warned[msg] = true
+		im.result.Warnings = append(im.result.Warnings, msg)
+	}
+}
+
+// Convert commit with its history, returns our hash
+func (im *importer) commit(sha []byte) ([]byte, error) {
+	if hash, ok := im.mapping[hex.EncodeToString(sha)]; ok {
+		return hash, nil
+	}
+	t, data, err := im.repo.ReadObject(sha)
+	if err != nil {
+		return nil, err
+	}
+	if t != TypeCommit {
+		return nil, fmt.Errorf("git object %x is %s, not commit", sha, t)
+	}
+	gc, err := DecodeCommit(data)
+	if err != nil {
+		return nil, err
+	}
+	commit := object.Commit{
+		Origin:      []byte{},
+		Author:      gc.Author,
+		Time:        gc.Time,
+		Description: bytes.TrimSuffix(gc.Message, []byte("\n")),
+	}
+	for i, parent := range gc.Parents {
+		hash, err := im.commit(parent)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			commit.Origin = hash
+		} else {
+			commit.Merges = append(commit.Merges, hash)
+		}
+	}
+	commit.Tree, err = im.tree(gc.Tree, "")
+	if err != nil {
+		return nil, err
+	}
+	obj, err := commit.CreateObject()
+	if err != nil {
+		return nil, err
+	}
+	im.result.Commits++
+	return im.add(sha, obj)
+}
+
+// Convert tree with its children, returns our hash. Path is used in warnings.
+func (im *importer) tree(sha []byte, path string) ([]byte, error) {
+	if hash, ok := im.mapping[hex.EncodeToString(sha)]; ok {
+		return hash, nil
+	}
+	t, data, err := im.repo.ReadObject(sha)
+	if err != nil {
+		return nil, err
+	}
+	if t != TypeTree {
+		return nil, fmt.Errorf("git object %x is %s, not tree", sha, t)
+	}
+	entries, err := DecodeTree(data)
+	if err != nil {
+		return nil, err
+	}
+	tree := object.Tree{
+		Children: make([]object.Child, 0, len(entries)),
+	}
+	for _, e := range entries {
+		name := path + string(e.Name)
+		if err := object.CheckEntryName(e.Name); err != nil {
+			im.warn("%s: %v, skipped", name, err)
+			continue
+		}
+		var hash []byte
+		childType := uint(object.TypeBlob)
+		var mode uint32
+		switch e.Mode {
+		case ModeTree:
+			childType = object.TypeTree
+			mode = object.ModeDirectory
+			hash, err = im.tree(e.Hash, name+"/")
+		case ModeBlob:
+			mode = object.ModeRegular
+			hash, err = im.blob(e.Hash)
+		case ModeExecutable:
+			mode = object.ModeExecutable
+			hash, err = im.blob(e.Hash)
+		case ModeSymlink:
+			mode = object.ModeSymlink
+			hash, err = im.blob(e.Hash)
+		case ModeSubmodule:
+			im.warn("%s: submodule skipped", name)
+			continue
+		default:
+			im.warn("%s: unknown mode %s, skipped", name, e.Mode)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		tree.Children = append(tree.Children, object.Child{
+			Type: childType,
+			Name: e.Name,
+			Hash: hash,
+			Mode: mode,
+		})
+	}
+	obj, err := tree.CreateObject()
+	if err != nil {
+		return nil, err
+	}
+	return im.add(sha, obj)
+}
+
+// Convert blob, returns our hash
+func (im *importer) blob(sha []byte) ([]byte, error) {
+	if hash, ok := im.mapping[hex.EncodeToString(sha)]; ok {
+		return hash, nil
+	}
+	t, data, err := im.repo.ReadObject(sha)
+	if err != nil {
+		return nil, err
+	}
+	if t != TypeBlob {
+		return nil, fmt.Errorf("git object %x is %s, not blob", sha, t)
+	}
+	blob := object.Blob{
+		Data: data,
+	}
+	return im.add(sha, blob.CreateObject())
+}
+
+// Remember converted object, writing batch to storage when it grows big
+func (im *importer) add(sha []byte, obj *object.Object) ([]byte, error) {
+	hash, err := obj.GetHash()
+	if err != nil {
+		return nil, err
+	}
+	im.mapping[hex.EncodeToString(sha)] = hash
+	im.objects[hex.EncodeToString(hash)] = obj
+	if len(im.objects) >= importBatchSize {
+		err = im.flush()
+	}
+	return hash, err
+}
+
+// Write converted objects to storage. Branches are updated only after all objects are
+// written, so interrupted import leaves no ref to missing objects.
+func (im *importer) flush() error {
+	stored, _, err := im.s.StoreObjects(im.objects)
+	if err != nil {
+		return err
+	}
+	im.result.Stored += stored
+	im.objects = make(map[string]*object.Object)
+	return nil
+}
@@ -0,0 +1,237 @@
+package git
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"mymodule/internal/object"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Git object types
+const (
+	TypeBlob   = "blob"
+	TypeTree   = "tree"
+	TypeCommit = "commit"
+	TypeTag    = "tag"
+)
+
+// Git tree entry modes
+const (
+	ModeBlob       = "100644"
+	ModeExecutable = "100755"
+	ModeSymlink    = "120000"
+	ModeTree       = "40000"
+	ModeSubmodule  = "160000"
+)
+
+// Entry of git tree
+type TreeEntry struct {
+	Mode string
+	Name []byte
+	Hash []byte //SHA-1
+}
+
+// Git commit
+type Commit struct {
+	Tree      []byte
+	Parents   [][]byte
+	Author    []byte //"Name <email>"
+	Time      int64
+	Zone      string //Time zone of author, e.g. "+0300"
+	Committer []byte
+	Message   []byte
+}
+
+// Git object id of content
+func Hash(t string, data []byte) []byte {
+	h := sha1.New()
+	fmt.Fprintf(h, "%s %d\x00", t, len(data))
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// Encode tree entries in git order: by name, directories compared as if followed by "/"
+func EncodeTree(entries []TreeEntry) []byte {
+	sorted := make([]TreeEntry, len(entries))
+	copy(sorted, entries)
+	sortKey := func(e TreeEntry) []byte {
+		if e.Mode == ModeTree {
+			return append(append([]byte{}, e.Name...), '/')
+		}
+		return e.Name
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sortKey(sorted[i]), sortKey(sorted[j])) < 0
+	})
+	var b bytes.Buffer
+	for _, e := range sorted {
+		fmt.Fprintf(&b, "%s %s\x00", e.Mode, e.Name)
+		b.Write(e.Hash)
+	}
+	return b.Bytes()
+}
+
+// Decode git tree
+func DecodeTree(data []byte) ([]TreeEntry, error) {
+	entries := make([]TreeEntry, 0)
+	for len(data) > 0 {
+		sp := bytes.IndexByte(data, ' ')
+		nul := bytes.IndexByte(data, 0)
+		if sp < 0 || nul < sp || len(data) < nul+1+sha1.Size {
+			return nil, fmt.Errorf("malformed git tree")
+		}
+		entries = append(entries, TreeEntry{
+			Mode: string(data[:sp]),
+			Name: append([]byte{}, data[sp+1:nul]...),
+			Hash: append([]byte{}, data[nul+1:nul+1+sha1.Size]...),
+		})
+		data = data[nul+1+sha1.Size:]
+	}
+	return entries, nil
+}
+
+// Encode git commit
+func EncodeCommit(c *Commit) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "tree %x\n", c.Tree)
+	for _, p := range c.Parents {
+		fmt.Fprintf(&b, "parent %x\n", p)
+	}
+	zone := c.Zone
+	if zone == "" {
+		zone = "+0000"
+	}
+	committer := c.Committer
+	if committer == nil {
+		committer = c.Author
+	}
+	fmt.Fprintf(&b, "author %s %d %s\n", c.Author, c.Time, zone)
+	fmt.Fprintf(&b, "committer %s %d %s\n", committer, c.Time, zone)
+	b.WriteString("\n")
+	b.Write(c.Message)
+	return b.Bytes()
+}
+
+// Decode git commit
+func DecodeCommit(data []byte) (*Commit, error) {
+	header, message, found := bytes.Cut(data, []byte("\n\n"))
+	if !found {
+		header, message = bytes.TrimSuffix(data, []byte("\n")), nil
+	}
+	c := &Commit{Message: message}
+	for _, line := range strings.Split(string(header), "\n") {
+		key, value, _ := strings.Cut(line, " ")
+		switch key {
+		case "tree":
+			hash, err := decodeHex(value)
+			if err != nil {
+				return nil, err
+			}
+			c.Tree = hash
+		case "parent":
+			hash, err := decodeHex(value)
+			if err != nil {
+				return nil, err
+			}
+			c.Parents = append(c.Parents, hash)
+		case "author":
+			ident, t, zone, err := parseIdent(value)
+			if err != nil {
+				return nil, err
+			}
+			c.Author, c.Time, c.Zone = ident, t, zone
+		case "committer":
+			ident, _, _, err := parseIdent(value)
+			if err != nil {
+				return nil, err
+			}
+			c.Committer = ident
+		}
+	}
+	if c.Tree == nil {
+		return nil, fmt.Errorf("git commit has no tree")
+	}
+	return c, nil
+}
+
+// Split "Name <email> 1234567890 +0300" into identity, time and zone
+func parseIdent(value string) ([]byte, int64, string, error) {
+	end := strings.LastIndex(value, ">")
+	if end < 0 {
+		return nil, 0, "", fmt.Errorf("malformed git identity \"%s\"", value)
+	}
+	fields := strings.Fields(value[end+1:])
+	var t int64
+	zone := "+0000"
+	if len(fields) > 0 {
+		var err error
+		t, err = strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			return nil, 0, "", err
+		}
+	}
+	if len(fields) > 1 {
+		zone = fields[1]
+	}
+	return []byte(value[:end+1]), t, zone, nil
+}
+
+// Git identity for author stored in our commit
+func Ident(author []byte) []byte {
+	a := strings.TrimSpace(string(author))
+	if strings.HasSuffix(a, ">") && strings.Contains(a, "<") {
+		return []byte(a)
+	}
+	if a == "" {
+		a = "unknown"
+	}
+	return []byte(a + " <>")
+}
+
+// Path of loose object in git directory
+func objectPath(gitDir string, hash []byte) string {
+	h := fmt.Sprintf("%x", hash)
+	return filepath.Join(gitDir, "objects", h[:2], h[2:])
+}
+
+// Write loose object if it does not exist, returns its id
+func WriteObject(gitDir string, t string, data []byte) ([]byte, bool, error) {
+	hash := Hash(t, data)
+	path := objectPath(gitDir, hash)
+	if _, err := os.Stat(path); err == nil {
+		return hash, false, nil
+	}
+	var raw bytes.Buffer
+	fmt.Fprintf(&raw, "%s %d\x00", t, len(data))
+	raw.Write(data)
+	zipped, err := object.Zip(raw.Bytes())
+	if err != nil {
+		return nil, false, err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return nil, false, err
+	}
+	// write into temporary file, so interrupted export never leaves broken object
+	tmp := path + ".tmp"
+	os.Remove(tmp)
+	err = os.WriteFile(tmp, zipped, 0444)
+	if err != nil {
+		return nil, false, err
+	}
+	return hash, true, os.Rename(tmp, path)
+}
+
+func decodeHex(value string) ([]byte, error) {
+	var hash []byte
+	_, err := fmt.Sscanf(value, "%x", &hash)
+	if err != nil || len(hash) != sha1.Size {
+		return nil, fmt.Errorf("malformed git hash \"%s\"", value)
+	}
+	return hash, nil
+}
@@ -0,0 +1,115 @@
+package git
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"reflect"
+	"testing"
+)
+
+func TestTreeRoundTrip(t *testing.T) {
+	hash := func(b byte) []byte {
+		return bytes.Repeat([]byte{b}, sha1.Size)
+	}
+	entries := []TreeEntry{
+		{Mode: ModeTree, Name: []byte("a"), Hash: hash(1)},
+		{Mode: ModeBlob, Name: []byte("a.txt"), Hash: hash(2)},
+		{Mode: ModeExecutable, Name: []byte("run"), Hash: hash(3)},
+		{Mode: ModeSymlink, Name: []byte("link"), Hash: hash(4)},
+		{Mode: ModeBlob, Name: []byte("a-b"), Hash: hash(5)},
+	}
+	// directory "a" sorts as "a/", after "a-b" and "a.txt"
+	wantOrder := []string{"a-b", "a.txt", "a", "link", "run"}
+
+	decoded, err := DecodeTree(EncodeTree(entries))
+	if err != nil {
+		t.Fatalf("DecodeTree() error: %v", err)
+	}
+	names := make([]string, 0, len(decoded))
+	for _, e := range decoded {
+		names = append(names, string(e.Name))
+		for _, orig := range entries {
+			if string(orig.Name) == string(e.Name) && !reflect.DeepEqual(orig, e) {
+				t.Errorf("entry %s = %+v, want %+v", e.Name, e, orig)
+			}
+		}
+	}
+	if !reflect.DeepEqual(names, wantOrder) {
+		t.Errorf("order = %v, want %v", names, wantOrder)
+	}
+}
+
+func TestDecodeTreeMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"no space", []byte("100644name\x00" + string(make([]byte, sha1.Size)))},
+		{"no name end", []byte("100644 name")},
+		{"short hash", []byte("100644 name\x00abc")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodeTree(tt.data); err == nil {
+				t.Errorf("DecodeTree() succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestCommitRoundTrip(t *testing.T) {
+	tree := bytes.Repeat([]byte{0xaa}, sha1.Size)
+	parent1 := bytes.Repeat([]byte{0xbb}, sha1.Size)
+	parent2 := bytes.Repeat([]byte{0xcc}, sha1.Size)
+
+	tests := []struct {
+		name   string
+		commit *Commit
+	}{
+		{
+			name: "root commit",
+			commit: &Commit{Tree: tree, Author: []byte("A <a@x>"), Time: 1700000000, Zone: "+0300",
+				Committer: []byte("A <a@x>"), Message: []byte("first\n")},
+		},
+		{
+			name: "merge with other committer",
+			commit: &Commit{Tree: tree, Parents: [][]byte{parent1, parent2}, Author: []byte("A <a@x>"),
+				Time: 1, Zone: "-0130", Committer: []byte("C <c@x>"), Message: []byte("merge\n\nbody\n")},
+		},
+		{
+			name: "empty message",
+			commit: &Commit{Tree: tree, Parents: [][]byte{parent1}, Author: []byte("A <a@x>"), Time: 0,
+				Zone: "+0000", Committer: []byte("A <a@x>"), Message: []byte{}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeCommit(EncodeCommit(tt.commit))
+			if err != nil {
+				t.Fatalf("DecodeCommit() error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.commit) {
+				t.Errorf("DecodeCommit() = %+v, want %+v", got, tt.commit)
+			}
+		})
+	}
+}
+
+func TestDecodeCommitMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"no tree", "author A <a@x> 1 +0000\n\nmsg"},
+		{"bad tree hash", "tree xyz\n\nmsg"},
+		{"bad parent hash", "tree " + string(bytes.Repeat([]byte("a"), 40)) + "\nparent 12\n\nmsg"},
+		{"author without email", "tree " + string(bytes.Repeat([]byte("a"), 40)) + "\nauthor A 1 +0000\n\nmsg"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodeCommit([]byte(tt.data)); err == nil {
+				t.Errorf("DecodeCommit() succeeded, want error")
+			}
+		})
+	}
+}
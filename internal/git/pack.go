@@ -0,0 +1,255 @@
+package git
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// Types of packed objects
+const (
+	packCommit   = 1
+	packTree     = 2
+	packBlob     = 3
+	packTag      = 4
+	packOfsDelta = 6
+	packRefDelta = 7
+)
+
+// Maximal number of resolved objects kept for delta chains
+const packCacheSize = 256
+
+// Pack file with version 2 index
+type pack struct {
+	data    []byte   //Content of .pack file
+	hashes  [][]byte //Sorted object ids from index
+	offsets []uint64 //Offsets of objects in pack, in order of hashes
+	cache   map[uint64]*packObject
+}
+
+type packObject struct {
+	t    string
+	data []byte
+}
+
+// Open pack by path without extension
+func openPack(path string) (*pack, error) {
+	idx, err := os.ReadFile(path + ".idx")
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path + ".pack")
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 12 || string(data[:4]) != "PACK" {
+		return nil, fmt.Errorf("%s.pack is not a pack file", path)
+	}
+	p := &pack{data: data, cache: make(map[uint64]*packObject)}
+	err = p.readIndex(idx)
+	if err != nil {
+		return nil, fmt.Errorf("%s.idx: %w", path, err)
+	}
+	return p, nil
+}
+
+// Read version 2 index: header, fan-out table, ids, crc32s, offsets, large offsets
+func (p *pack) readIndex(idx []byte) error {
+	if len(idx) < 8+256*4 || !bytes.Equal(idx[:4], []byte{0xff, 't', 'O', 'c'}) || binary.BigEndian.Uint32(idx[4:]) != 2 {
+		return errors.New("unsupported pack index version")
+	}
+	n := int(binary.BigEndian.Uint32(idx[8+255*4:]))
+	hashesStart := 8 + 256*4
+	offsetsStart := hashesStart + n*sha1.Size + n*4
+	largeStart := offsetsStart + n*4
+	if len(idx) < largeStart {
+		return errors.New("truncated pack index")
+	}
+	p.hashes = make([][]byte, n)
+	p.offsets = make([]uint64, n)
+	for i := 0; i < n; i++ {
+		p.hashes[i] = idx[hashesStart+i*sha1.Size : hashesStart+(i+1)*sha1.Size]
+		offset := uint64(binary.BigEndian.Uint32(idx[offsetsStart+i*4:]))
+		if offset&0x80000000 != 0 {
+			pos := largeStart + int(offset&0x7fffffff)*8
+			if len(idx) < pos+8 {
+				return errors.New("truncated pack index")
+			}
+			offset = binary.BigEndian.Uint64(idx[pos:])
+		}
+		p.offsets[i] = offset
+	}
+	return nil
+}
+
+// Find offset of object in pack
+func (p *pack) find(hash []byte) (uint64, bool) {
+	i := sort.Search(len(p.hashes), func(i int) bool {
+		return bytes.Compare(p.hashes[i], hash) >= 0
+	})
+	if i < len(p.hashes) && bytes.Equal(p.hashes[i], hash) {
+		return p.offsets[i], true
+	}
+	return 0, false
+}
+
+// Read object at offset, resolving deltas. Repository is used for bases of ref deltas.
+func (p *pack) read(offset uint64, r *Repository) (string, []byte, error) {
+	if obj, ok := p.cache[offset]; ok {
+		return obj.t, obj.data, nil
+	}
+	if offset >= uint64(len(p.data)) {
+		return "", nil, errors.New("pack offset out of range")
+	}
+	// header: type in bits 4-6 of first byte, size in varint of the rest
+	pos := offset
+	c := p.data[pos]
+	pos++
+	kind := (c >> 4) & 7
+	for c&0x80 != 0 && pos < uint64(len(p.data)) {
+		c = p.data[pos]
+		pos++
+	}
+
+	var t string
+	var data []byte
+	var err error
+	switch kind {
+	case packCommit, packTree, packBlob, packTag:
+		t = [...]string{packCommit: TypeCommit, packTree: TypeTree, packBlob: TypeBlob, packTag: TypeTag}[kind]
+		data, err = p.inflate(pos)
+	case packOfsDelta:
+		// negative offset of base, big-endian base-128 with implicit +1 on continuation
+		if pos >= uint64(len(p.data)) {
+			return "", nil, errors.New("truncated pack")
+		}
+		c = p.data[pos]
+		pos++
+		back := uint64(c & 0x7f)
+		for c&0x80 != 0 {
+			if pos >= uint64(len(p.data)) {
+				return "", nil, errors.New("truncated pack")
+			}
+			c = p.data[pos]
+			pos++
+			back = ((back + 1) << 7) | uint64(c&0x7f)
+		}
+		if back > offset {
+			return "", nil, errors.New("malformed delta offset")
+		}
+		var base []byte
+		t, base, err = p.read(offset-back, r)
+		if err != nil {
+			return "", nil, err
+		}
+		data, err = p.delta(pos, base)
+	case packRefDelta:
+		if pos+sha1.Size > uint64(len(p.data)) {
+			return "", nil, errors.New("truncated pack")
+		}
+		var base []byte
+		t, base, err = r.ReadObject(p.data[pos : pos+sha1.Size])
+		if err != nil {
+			return "", nil, err
+		}
+		data, err = p.delta(pos+sha1.Size, base)
+	default:
+		return "", nil, fmt.Errorf("unknown packed object type %d", kind)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	if len(p.cache) >= packCacheSize {
+		p.cache = make(map[uint64]*packObject)
+	}
+	p.cache[offset] = &packObject{t, data}
+	return t, data, nil
+}
+
+// Decompress zlib stream starting at pos
+func (p *pack) inflate(pos uint64) ([]byte, error) {
+	if pos >= uint64(len(p.data)) {
+		return nil, errors.New("truncated pack")
+	}
+	r, err := zlib.NewReader(bytes.NewReader(p.data[pos:]))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// Apply delta stored at pos to base
+func (p *pack) delta(pos uint64, base []byte) ([]byte, error) {
+	delta, err := p.inflate(pos)
+	if err != nil {
+		return nil, err
+	}
+	return applyDelta(base, delta)
+}
+
+// Build object from base and delta: sizes of base and result, then instructions that
+// copy ranges of base or insert literal bytes
+func applyDelta(base []byte, delta []byte) ([]byte, error) {
+	baseSize, n := binary.Uvarint(delta)
+	if n <= 0 || baseSize != uint64(len(base)) {
+		return nil, errors.New("delta base size mismatch")
+	}
+	delta = delta[n:]
+	resultSize, n := binary.Uvarint(delta)
+	if n <= 0 {
+		return nil, errors.New("malformed delta")
+	}
+	delta = delta[n:]
+
+	result := make([]byte, 0, resultSize)
+	for len(delta) > 0 {
+		cmd := delta[0]
+		delta = delta[1:]
+		switch {
+		case cmd&0x80 != 0:
+			// copy from base: present bytes of offset and size are flagged in cmd
+			var offset, size uint64
+			for i := 0; i < 7; i++ {
+				if cmd&(1<<i) == 0 {
+					continue
+				}
+				if len(delta) == 0 {
+					return nil, errors.New("malformed delta")
+				}
+				if i < 4 {
+					offset |= uint64(delta[0]) << (8 * i)
+				} else {
+					size |= uint64(delta[0]) << (8 * (i - 4))
+				}
+				delta = delta[1:]
+			}
+			if size == 0 {
+				size = 0x10000
+			}
+			if offset+size > uint64(len(base)) {
+				return nil, errors.New("delta copy out of range")
+			}
+			result = append(result, base[offset:offset+size]...)
+		case cmd != 0:
+			// insert next cmd bytes
+			if int(cmd) > len(delta) {
+				return nil, errors.New("malformed delta")
+			}
+			result = append(result, delta[:cmd]...)
+			delta = delta[cmd:]
+		default:
+			return nil, errors.New("malformed delta")
+		}
+	}
+	if uint64(len(result)) != resultSize {
+		return nil, errors.New("delta result size mismatch")
+	}
+	return result, nil
+}
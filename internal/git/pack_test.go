@@ -0,0 +1,206 @@
+package git
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"testing"
+)
+
+func TestApplyDelta(t *testing.T) {
+	base := []byte("0123456789abcdef")
+	large := bytes.Repeat([]byte("x"), 0x10000)
+
+	tests := []struct {
+		name  string
+		base  []byte
+		delta []byte
+		want  []byte
+		fails bool
+	}{
+		{
+			name:  "insert only",
+			base:  base,
+			delta: []byte{16, 3, 3, 'a', 'b', 'c'},
+			want:  []byte("abc"),
+		},
+		{
+			name:  "copy with one byte offset and size",
+			base:  base,
+			delta: []byte{16, 4, 0x80 | 0x01 | 0x10, 10, 4},
+			want:  []byte("abcd"),
+		},
+		{
+			name:  "copy from start without offset bytes",
+			base:  base,
+			delta: []byte{16, 3, 0x80 | 0x10, 3},
+			want:  []byte("012"),
+		},
+		{
+			name:  "copy and insert mixed",
+			base:  base,
+			delta: []byte{16, 7, 0x80 | 0x10, 2, 2, '-', '-', 0x80 | 0x01 | 0x10, 14, 2, 0x01, '!'},
+			want:  []byte("01--ef!"),
+		},
+		{
+			name:  "zero size means 0x10000",
+			base:  large,
+			delta: []byte{0x80, 0x80, 0x04, 0x80, 0x80, 0x04, 0x80},
+			want:  large,
+		},
+		{
+			name:  "two byte offset",
+			base:  append(bytes.Repeat([]byte("-"), 0x100), 'z'),
+			delta: []byte{0x81, 0x02, 1, 0x80 | 0x02 | 0x10, 0x01, 1},
+			want:  []byte("z"),
+		},
+		{
+			name:  "base size mismatch",
+			base:  base,
+			delta: []byte{15, 1, 1, 'a'},
+			fails: true,
+		},
+		{
+			name:  "result size mismatch",
+			base:  base,
+			delta: []byte{16, 2, 1, 'a'},
+			fails: true,
+		},
+		{
+			name:  "copy out of range",
+			base:  base,
+			delta: []byte{16, 4, 0x80 | 0x01 | 0x10, 14, 4},
+			fails: true,
+		},
+		{
+			name:  "insert longer than delta",
+			base:  base,
+			delta: []byte{16, 3, 3, 'a'},
+			fails: true,
+		},
+		{
+			name:  "copy missing size byte",
+			base:  base,
+			delta: []byte{16, 1, 0x80 | 0x10},
+			fails: true,
+		},
+		{
+			name:  "reserved zero instruction",
+			base:  base,
+			delta: []byte{16, 0, 0},
+			fails: true,
+		},
+		{
+			name:  "empty delta",
+			base:  base,
+			delta: []byte{},
+			fails: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := applyDelta(tt.base, tt.delta)
+			if tt.fails {
+				if err == nil {
+					t.Fatalf("applyDelta() = %q, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("applyDelta() error: %v", err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("applyDelta() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// Version 2 index of objects with given ids (sorted) and offsets; offsets that don't fit
+// in 31 bits go to the large offset table
+func buildIndex(hashes [][]byte, offsets []uint64) []byte {
+	idx := []byte{0xff, 't', 'O', 'c', 0, 0, 0, 2}
+	for b := 0; b < 256; b++ {
+		count := 0
+		for _, h := range hashes {
+			if int(h[0]) <= b {
+				count++
+			}
+		}
+		idx = binary.BigEndian.AppendUint32(idx, uint32(count))
+	}
+	for _, h := range hashes {
+		idx = append(idx, h...)
+	}
+	idx = append(idx, make([]byte, 4*len(hashes))...)
+	large := make([]byte, 0)
+	for _, offset := range offsets {
+		if offset < 0x80000000 {
+			idx = binary.BigEndian.AppendUint32(idx, uint32(offset))
+			continue
+		}
+		idx = binary.BigEndian.AppendUint32(idx, 0x80000000|uint32(len(large)/8))
+		large = binary.BigEndian.AppendUint64(large, offset)
+	}
+	return append(idx, large...)
+}
+
+func TestReadIndex(t *testing.T) {
+	id := func(first byte) []byte {
+		return bytes.Repeat([]byte{first}, sha1.Size)
+	}
+	hashes := [][]byte{id(0x01), id(0x7f), id(0xfe)}
+	offsets := []uint64{12, 0x1_0000_0000, 4096}
+	p := &pack{}
+	err := p.readIndex(buildIndex(hashes, offsets))
+	if err != nil {
+		t.Fatalf("readIndex() error: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		hash   []byte
+		offset uint64
+		found  bool
+	}{
+		{"first", id(0x01), 12, true},
+		{"large offset", id(0x7f), 0x1_0000_0000, true},
+		{"last", id(0xfe), 4096, true},
+		{"before first", id(0x00), 0, false},
+		{"between", id(0x80), 0, false},
+		{"after last", id(0xff), 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			offset, found := p.find(tt.hash)
+			if found != tt.found || offset != tt.offset {
+				t.Errorf("find() = %d, %v, want %d, %v", offset, found, tt.offset, tt.found)
+			}
+		})
+	}
+}
+
+func TestReadIndexMalformed(t *testing.T) {
+	valid := buildIndex([][]byte{bytes.Repeat([]byte{1}, sha1.Size)}, []uint64{0x1_0000_0000})
+	version1 := append([]byte{}, valid...)
+	version1[7] = 1
+
+	tests := []struct {
+		name string
+		idx  []byte
+	}{
+		{"empty", []byte{}},
+		{"wrong magic", append([]byte("PACK"), valid[4:]...)},
+		{"version 1", version1},
+		{"truncated table", valid[:8+256*4+sha1.Size]},
+		{"truncated large offset", valid[:len(valid)-4]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &pack{}
+			if err := p.readIndex(tt.idx); err == nil {
+				t.Errorf("readIndex() succeeded, want error")
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"mymodule/internal/object"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Local git repository opened for reading
+type Repository struct {
+	gitDir string
+	packs  []*pack
+}
+
+// Open git repository: working tree containing .git or bare repository directory
+func OpenRepository(path string) (*Repository, error) {
+	gitDir := path
+	if stat, err := os.Stat(filepath.Join(path, ".git")); err == nil && stat.IsDir() {
+		gitDir = filepath.Join(path, ".git")
+	}
+	if _, err := os.Stat(filepath.Join(gitDir, "objects")); err != nil {
+		return nil, fmt.Errorf("%s is not a git repository", path)
+	}
+	r := &Repository{gitDir: gitDir}
+	idxFiles, err := filepath.Glob(filepath.Join(gitDir, "objects", "pack", "*.idx"))
+	if err != nil {
+		return nil, err
+	}
+	for _, idx := range idxFiles {
+		p, err := openPack(strings.TrimSuffix(idx, ".idx"))
+		if err != nil {
+			return nil, err
+		}
+		r.packs = append(r.packs, p)
+	}
+	return r, nil
+}
+
+// Read object by id, returns its type and content
+func (r *Repository) ReadObject(hash []byte) (string, []byte, error) {
+	raw, err := os.ReadFile(objectPath(r.gitDir, hash))
+	if err == nil {
+		return parseLoose(raw)
+	}
+	if !os.IsNotExist(err) {
+		return "", nil, err
+	}
+	for _, p := range r.packs {
+		if offset, ok := p.find(hash); ok {
+			return p.read(offset, r)
+		}
+	}
+	return "", nil, fmt.Errorf("git object %x not found", hash)
+}
+
+// Parse zipped loose object "<type> <size>\0<content>"
+func parseLoose(raw []byte) (string, []byte, error) {
+	data, err := object.Unzip(raw)
+	if err != nil {
+		return "", nil, err
+	}
+	header, content, found := bytes.Cut(data, []byte{0})
+	if !found {
+		return "", nil, fmt.Errorf("malformed git object")
+	}
+	t, size, _ := strings.Cut(string(header), " ")
+	if n, err := strconv.Atoi(size); err != nil || n != len(content) {
+		return "", nil, fmt.Errorf("malformed git object")
+	}
+	return t, content, nil
+}
+
+// All refs of repository by full name, e.g. "refs/heads/master"
+func (r *Repository) Refs() (map[string][]byte, error) {
+	refs := make(map[string][]byte)
+
+	f, err := os.Open(filepath.Join(r.gitDir, "packed-refs"))
+	if err == nil {
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := scanner.Text()
+			// comments and peeled values of tags
+			if strings.HasPrefix(line, "#") || strings.HasPrefix(line, "^") {
+				continue
+			}
+			sha, name, found := strings.Cut(line, " ")
+			if !found {
+				continue
+			}
+			hash, err := hex.DecodeString(sha)
+			if err != nil {
+				return nil, err
+			}
+			refs[name] = hash
+		}
+		f.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	// loose refs override packed ones
+	root := filepath.Join(r.gitDir, "refs")
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		value := strings.TrimSpace(string(data))
+		if strings.HasPrefix(value, "ref: ") {
+			return nil
+		}
+		hash, err := hex.DecodeString(value)
+		if err != nil {
+			return fmt.Errorf("malformed ref %s", path)
+		}
+		name, _ := filepath.Rel(r.gitDir, path)
+		refs[filepath.ToSlash(name)] = hash
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return refs, nil
+}
+
+// Name of branch HEAD points to, empty if HEAD is detached
+func (r *Repository) HeadBranch() string {
+	data, err := os.ReadFile(filepath.Join(r.gitDir, "HEAD"))
+	if err != nil {
+		return ""
+	}
+	ref, found := strings.CutPrefix(strings.TrimSpace(string(data)), "ref: refs/heads/")
+	if !found {
+		return ""
+	}
+	return ref
+}
@@ -0,0 +1,207 @@
+package history
+
+import (
+	"bytes"
+	"container/heap"
+	"fmt"
+	"mymodule/internal/object"
+	"mymodule/internal/storage"
+	"strings"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// Line of file with commit that introduced it
+type BlameLine struct {
+	Number int    //Number of line in blamed file, from 1
+	Text   string //Line without line break
+	Commit *storage.CommitData
+}
+
+// Version of file in commit with lines not yet attributed to any commit
+type blameJob struct {
+	commit  *storage.CommitData
+	blob    []byte
+	pending []blamePending
+}
+
+// Line of file version and index of the same line in blamed file
+type blamePending struct {
+	line  int
+	final int
+}
+
+// Attribute lines from..to (from 1, inclusive, 0 for whole file) of file at path in commit
+// to commits that introduced them. Lines of commit are passed to its parents while line
+// diff shows them unchanged; lines left are introduced by commit. Renames are not followed.
+func Blame(s *storage.Storage, hash []byte, path string, from int, to int) ([]*BlameLine, error) {
+	commitData, err := s.GetCommit(hash)
+	if err != nil {
+		return nil, err
+	}
+	blob, err := blobAt(s, commitData, path)
+	if err != nil {
+		return nil, err
+	}
+	if blob == nil {
+		return nil, fmt.Errorf("path %s not found in commit %x", path, hash)
+	}
+	obj, err := s.GetObject(blob)
+	if err != nil {
+		return nil, err
+	}
+	b, err := obj.ParseBlob()
+	if err != nil {
+		return nil, err
+	}
+	lines := splitLines(string(b.Data))
+	if len(lines) == 0 {
+		return []*BlameLine{}, nil
+	}
+	if from == 0 {
+		from, to = 1, len(lines)
+	}
+	if to > len(lines) {
+		to = len(lines)
+	}
+	if from < 1 || from > to {
+		return nil, fmt.Errorf("file %s has only %d lines", path, len(lines))
+	}
+
+	result := make([]*BlameLine, 0, to-from+1)
+	first := &blameJob{commit: commitData, blob: blob}
+	for i := from - 1; i < to; i++ {
+		result = append(result, &BlameLine{Number: i + 1, Text: lines[i]})
+		first.pending = append(first.pending, blamePending{i, i - from + 1})
+	}
+
+	cmp := object.Comparator{
+		GetFunction1: s.GetObject,
+		GetFunction2: s.GetObject,
+	}
+	queue := &blameQueue{}
+	jobs := map[string]*blameJob{string(hash): first}
+	heap.Push(queue, first)
+	for queue.Len() > 0 {
+		job := heap.Pop(queue).(*blameJob)
+		delete(jobs, string(job.commit.Hash))
+		pending := job.pending
+		for _, parent := range Parents(job.commit) {
+			if len(pending) == 0 {
+				break
+			}
+			parentData, err := s.GetCommit(parent)
+			if err != nil {
+				return nil, err
+			}
+			parentBlob, err := blobAt(s, parentData, path)
+			if err != nil {
+				return nil, err
+			}
+			if parentBlob == nil {
+				continue
+			}
+			var passed []blamePending
+			if bytes.Equal(parentBlob, job.blob) {
+				passed, pending = pending, nil
+			} else {
+				diffs, err := cmp.CompareBlobs(parentBlob, job.blob)
+				if err != nil {
+					return nil, err
+				}
+				passed, pending = passLines(diffs, pending)
+			}
+			if len(passed) == 0 {
+				continue
+			}
+			if j, ok := jobs[string(parent)]; ok {
+				j.pending = append(j.pending, passed...)
+				continue
+			}
+			j := &blameJob{commit: parentData, blob: parentBlob, pending: passed}
+			jobs[string(parent)] = j
+			heap.Push(queue, j)
+		}
+		for _, p := range pending {
+			result[p.final].Commit = job.commit
+		}
+	}
+	return result, nil
+}
+
+// Split pending lines into ones unchanged by diffs, renumbered as lines of old version,
+// and ones inserted by diffs
+func passLines(diffs []diffmatchpatch.Diff, pending []blamePending) (passed []blamePending, kept []blamePending) {
+	old := make(map[int]int) //Line of new version to line of old version
+	oldLine, newLine := 0, 0
+	for _, d := range diffs {
+		n := len(splitLines(d.Text))
+		switch d.Type {
+		case diffmatchpatch.DiffEqual:
+			for i := 0; i < n; i++ {
+				old[newLine+i] = oldLine + i
+			}
+			oldLine += n
+			newLine += n
+		case diffmatchpatch.DiffDelete:
+			oldLine += n
+		case diffmatchpatch.DiffInsert:
+			newLine += n
+		}
+	}
+	for _, p := range pending {
+		if line, ok := old[p.line]; ok {
+			passed = append(passed, blamePending{line, p.final})
+		} else {
+			kept = append(kept, p)
+		}
+	}
+	return passed, kept
+}
+
+// Hash of blob at path in commit, nil if there is no file
+func blobAt(s *storage.Storage, commitData *storage.CommitData, path string) ([]byte, error) {
+	entry, err := s.FindEntry(commitData.Commit.Tree, path)
+	if err != nil || entry == nil || entry.Type != object.TypeBlob {
+		return nil, err
+	}
+	return entry.Hash, nil
+}
+
+// Lines of text without line breaks
+func splitLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	for i := range lines {
+		lines[i] = strings.TrimSuffix(lines[i], "\n")
+	}
+	return lines
+}
+
+// Versions waiting for attribution, newest commit first
+type blameQueue []*blameJob
+
+func (q blameQueue) Len() int {
+	return len(q)
+}
+
+func (q blameQueue) Less(i, j int) bool {
+	return q[i].commit.Commit.Time > q[j].commit.Commit.Time
+}
+
+func (q blameQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+}
+
+func (q *blameQueue) Push(x any) {
+	*q = append(*q, x.(*blameJob))
+}
+
+func (q *blameQueue) Pop() any {
+	old := *q
+	last := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return last
+}
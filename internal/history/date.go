@@ -0,0 +1,70 @@
+package history
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Absolute date formats, in local time unless zone is given
+var dateFormats = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"02.01.2006 15:04:05",
+	"02.01.2006",
+}
+
+var relativeDate = regexp.MustCompile(`^(\d+)[ .]?(second|minute|hour|day|week|month|year)s?(?:[ .]ago)?$`)
+
+// Parse date of --since/--until: absolute date in one of dateFormats, "now", "today",
+// "yesterday" or relative date like "2 weeks ago" counted back from now.
+func ParseDate(value string, now time.Time) (int64, error) {
+	value = strings.TrimSpace(value)
+	for _, format := range dateFormats {
+		t, err := time.ParseInLocation(format, value, now.Location())
+		if err == nil {
+			return t.Unix(), nil
+		}
+	}
+	value = strings.ToLower(value)
+
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch value {
+	case "now":
+		return now.Unix(), nil
+	case "today":
+		return midnight.Unix(), nil
+	case "yesterday":
+		return midnight.AddDate(0, 0, -1).Unix(), nil
+	}
+
+	m := relativeDate.FindStringSubmatch(value)
+	if m == nil {
+		return 0, fmt.Errorf("unknown date \"%s\"", value)
+	}
+	n, err := strconv.Atoi(m[1])
+	if err != nil {
+		return 0, fmt.Errorf("unknown date \"%s\"", value)
+	}
+	switch m[2] {
+	case "second":
+		now = now.Add(-time.Duration(n) * time.Second)
+	case "minute":
+		now = now.Add(-time.Duration(n) * time.Minute)
+	case "hour":
+		now = now.Add(-time.Duration(n) * time.Hour)
+	case "day":
+		now = now.AddDate(0, 0, -n)
+	case "week":
+		now = now.AddDate(0, 0, -7*n)
+	case "month":
+		now = now.AddDate(0, -n, 0)
+	case "year":
+		now = now.AddDate(-n, 0, 0)
+	}
+	return now.Unix(), nil
+}
@@ -0,0 +1,129 @@
+package history
+
+import (
+	"bytes"
+	"strings"
+)
+
+// ASCII drawing of history, one column per line of development. Commits must be passed
+// in topological order.
+type Graph struct {
+	columns [][]byte //Hash of commit expected next in each column
+}
+
+// Lines drawn for one commit
+type Row struct {
+	Before  []string //Columns waiting for commit joining into one
+	Line    string   //Columns with "*" for commit
+	Padding string   //Columns next to text of commit
+	After   []string //Column of commit splitting into its parents
+}
+
+// Move of column between two lines
+type move struct {
+	from int
+	to   int
+}
+
+// Add commit to graph
+func (g *Graph) Commit(hash []byte, parents [][]byte) *Row {
+	row := &Row{}
+	index := -1
+	joined := false
+	for i, c := range g.columns {
+		if bytes.Equal(c, hash) {
+			if index < 0 {
+				index = i
+			} else {
+				joined = true
+			}
+		}
+	}
+	if index < 0 {
+		// head of new line
+		g.columns = append(g.columns, hash)
+		index = len(g.columns) - 1
+	}
+
+	if joined {
+		moves := make([]move, 0, len(g.columns))
+		columns := make([][]byte, 0, len(g.columns))
+		for i, c := range g.columns {
+			if bytes.Equal(c, hash) && i != index {
+				moves = append(moves, move{i, index})
+				continue
+			}
+			moves = append(moves, move{i, len(columns)})
+			columns = append(columns, c)
+		}
+		row.Before = draw(moves)
+		g.columns = columns
+	}
+
+	row.Line = lanes(len(g.columns), index, "*")
+	if len(parents) > 0 {
+		row.Padding = lanes(len(g.columns), index, "|")
+	} else {
+		row.Padding = lanes(len(g.columns), index, " ")
+	}
+
+	moves := make([]move, 0, len(g.columns)+len(parents))
+	columns := make([][]byte, 0, len(g.columns)+len(parents))
+	for i, c := range g.columns {
+		if i != index {
+			moves = append(moves, move{i, len(columns)})
+			columns = append(columns, c)
+			continue
+		}
+		for _, p := range parents {
+			moves = append(moves, move{i, len(columns)})
+			columns = append(columns, p)
+		}
+	}
+	row.After = draw(moves)
+	g.columns = columns
+	return row
+}
+
+// Columns with mark in column index
+func lanes(n int, index int, mark string) string {
+	parts := make([]string, n)
+	for i := range parts {
+		parts[i] = "|"
+	}
+	parts[index] = mark
+	return strings.TrimRight(strings.Join(parts, " "), " ")
+}
+
+// Lines moving columns to new places, one step per line. Moves never cross, since
+// columns keep their order.
+func draw(moves []move) []string {
+	lines := make([]string, 0)
+	for {
+		width := 0
+		done := true
+		for _, m := range moves {
+			width = max(width, 2*m.from+2, 2*m.to+2)
+			if m.from != m.to {
+				done = false
+			}
+		}
+		if done {
+			return lines
+		}
+		line := []byte(strings.Repeat(" ", width))
+		for i, m := range moves {
+			switch {
+			case m.from == m.to:
+				line[2*m.from] = '|'
+			case m.to < m.from:
+				line[2*m.from-1] = '/'
+				moves[i].from--
+			default:
+				line[2*m.from+1] = '\\'
+				moves[i].from++
+			}
+		}
+		lines = append(lines, strings.TrimRight(string(line), " "))
+	}
+}
@@ -0,0 +1,269 @@
+package history
+
+import (
+	"bytes"
+	"container/heap"
+	"mymodule/internal/storage"
+	"regexp"
+)
+
+// Conditions of commits shown in history
+type Filter struct {
+	Author  *regexp.Regexp //Pattern of author, nil for any
+	Grep    *regexp.Regexp //Pattern of description, nil for any
+	Since   int64          //Oldest time of commit, 0 for no limit
+	Until   int64          //Newest time of commit, 0 for no limit
+	Skip    int            //Number of matching commits left out
+	Count   int            //Maximal number of commits shown, 0 for no limit
+	Exclude [][]byte       //Commits reachable from these are not shown
+	Graph   bool           //Entries are drawn as graph and need their shown parents
+}
+
+// Commit shown in history
+type Entry struct {
+	*storage.CommitData
+	Parents [][]byte //Nearest shown ancestors, lines of graph go to them; nil if walk stopped early
+}
+
+// Commits reachable from heads and matching filter in topological order: every commit
+// comes before its parents, among commits ready to be shown the newest one goes first.
+// Parents of commit older than Since and of excluded commits are not read at all, so
+// history is cut there assuming that parents are never newer than their children.
+// Without graph, commits limited by Count are instead read newest first by time and the
+// walk stops as soon as Skip+Count of them match.
+func Walk(s *storage.Storage, heads [][]byte, filter *Filter) ([]*Entry, error) {
+	excluded, err := reachable(s, filter.Exclude, filter.Since)
+	if err != nil {
+		return nil, err
+	}
+	if !filter.Graph && filter.Count > 0 {
+		return walkNewest(s, heads, excluded, filter)
+	}
+
+	commits := make(map[string]*storage.CommitData) //nil for commits left out by Since
+	order := make(map[string]int)                   //Order of discovery, breaks ties of equal time
+	children := make(map[string]int)
+	queue := make([][]byte, 0, len(heads))
+	for _, head := range heads {
+		if _, ok := commits[string(head)]; len(head) > 0 && !ok && !excluded[string(head)] {
+			queue = append(queue, head)
+			commits[string(head)] = nil
+		}
+	}
+	// collect commits, counting children of each
+	for i := 0; i < len(queue); i++ {
+		commitData, err := s.GetCommit(queue[i])
+		if err != nil {
+			return nil, err
+		}
+		if commitData.Commit.Time < filter.Since {
+			continue
+		}
+		key := string(queue[i])
+		commits[key] = commitData
+		order[key] = i
+		for _, parent := range Parents(commitData) {
+			if excluded[string(parent)] {
+				continue
+			}
+			children[string(parent)]++
+			if _, ok := commits[string(parent)]; !ok {
+				commits[string(parent)] = nil
+				queue = append(queue, parent)
+			}
+		}
+	}
+
+	ready := &readyQueue{order: order}
+	for _, hash := range queue {
+		if commits[string(hash)] != nil && children[string(hash)] == 0 {
+			heap.Push(ready, commits[string(hash)])
+		}
+	}
+	sorted := make([]*storage.CommitData, 0, len(queue))
+	for ready.Len() > 0 {
+		commitData := heap.Pop(ready).(*storage.CommitData)
+		sorted = append(sorted, commitData)
+		for _, parent := range Parents(commitData) {
+			children[string(parent)]--
+			if children[string(parent)] == 0 && commits[string(parent)] != nil {
+				heap.Push(ready, commits[string(parent)])
+			}
+		}
+	}
+
+	shown := make(map[string]bool)
+	matched := 0
+	for _, commitData := range sorted {
+		if !filter.matches(commitData) {
+			continue
+		}
+		matched++
+		if matched > filter.Skip && (filter.Count == 0 || matched <= filter.Skip+filter.Count) {
+			shown[string(commitData.Hash)] = true
+		}
+	}
+
+	// hidden commits are replaced in graph by their nearest shown ancestors,
+	// parents are visited before children
+	nearest := make(map[string][][]byte)
+	entries := make([]*Entry, 0, len(shown))
+	for i := len(sorted) - 1; i >= 0; i-- {
+		commitData := sorted[i]
+		parents := make([][]byte, 0)
+		for _, parent := range Parents(commitData) {
+			for _, p := range nearest[string(parent)] {
+				if !contains(parents, p) {
+					parents = append(parents, p)
+				}
+			}
+		}
+		if !shown[string(commitData.Hash)] {
+			nearest[string(commitData.Hash)] = parents
+			continue
+		}
+		nearest[string(commitData.Hash)] = [][]byte{commitData.Hash}
+		entries = append(entries, &Entry{commitData, parents})
+	}
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	return entries, nil
+}
+
+// Commits reachable from heads, newest first, until Skip+Count of them match filter
+func walkNewest(s *storage.Storage, heads [][]byte, excluded map[string]bool, filter *Filter) ([]*Entry, error) {
+	order := make(map[string]int)
+	ready := &readyQueue{order: order}
+	push := func(hash []byte) error {
+		if _, ok := order[string(hash)]; len(hash) == 0 || ok || excluded[string(hash)] {
+			return nil
+		}
+		order[string(hash)] = len(order)
+		commitData, err := s.GetCommit(hash)
+		if err != nil {
+			return err
+		}
+		heap.Push(ready, commitData)
+		return nil
+	}
+	for _, head := range heads {
+		if err := push(head); err != nil {
+			return nil, err
+		}
+	}
+
+	entries := make([]*Entry, 0, filter.Count)
+	matched := 0
+	for ready.Len() > 0 && matched < filter.Skip+filter.Count {
+		commitData := heap.Pop(ready).(*storage.CommitData)
+		if commitData.Commit.Time < filter.Since {
+			continue
+		}
+		if filter.matches(commitData) {
+			matched++
+			if matched > filter.Skip {
+				entries = append(entries, &Entry{CommitData: commitData})
+			}
+		}
+		for _, parent := range Parents(commitData) {
+			if err := push(parent); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return entries, nil
+}
+
+// Check if commit satisfies conditions of filter other than position
+func (f *Filter) matches(commitData *storage.CommitData) bool {
+	commit := commitData.Commit
+	if f.Until != 0 && commit.Time > f.Until {
+		return false
+	}
+	if f.Author != nil && !f.Author.Match(commit.Author) {
+		return false
+	}
+	if f.Grep != nil && !f.Grep.Match(commit.Description) {
+		return false
+	}
+	return true
+}
+
+// Set of commits reachable from heads, not going below commits older than since
+func reachable(s *storage.Storage, heads [][]byte, since int64) (map[string]bool, error) {
+	visited := make(map[string]bool)
+	queue := append([][]byte{}, heads...)
+	for len(queue) > 0 {
+		hash := queue[0]
+		queue = queue[1:]
+		if len(hash) == 0 || visited[string(hash)] {
+			continue
+		}
+		visited[string(hash)] = true
+		commitData, err := s.GetCommit(hash)
+		if err != nil {
+			return nil, err
+		}
+		if commitData.Commit.Time < since {
+			continue
+		}
+		queue = append(queue, Parents(commitData)...)
+	}
+	return visited, nil
+}
+
+// Parents of commit: origin first, then merged commits
+func Parents(commitData *storage.CommitData) [][]byte {
+	parents := make([][]byte, 0, 1+len(commitData.Commit.Merges))
+	if len(commitData.Commit.Origin) > 0 {
+		parents = append(parents, commitData.Commit.Origin)
+	}
+	for _, m := range commitData.Commit.Merges {
+		if len(m) > 0 {
+			parents = append(parents, m)
+		}
+	}
+	return parents
+}
+
+func contains(hashes [][]byte, hash []byte) bool {
+	for _, h := range hashes {
+		if bytes.Equal(h, hash) {
+			return true
+		}
+	}
+	return false
+}
+
+// Commits whose children are all sorted, newest first
+type readyQueue struct {
+	commits []*storage.CommitData
+	order   map[string]int
+}
+
+func (q *readyQueue) Len() int {
+	return len(q.commits)
+}
+
+func (q *readyQueue) Less(i, j int) bool {
+	ci, cj := q.commits[i], q.commits[j]
+	if ci.Commit.Time != cj.Commit.Time {
+		return ci.Commit.Time > cj.Commit.Time
+	}
+	return q.order[string(ci.Hash)] < q.order[string(cj.Hash)]
+}
+
+func (q *readyQueue) Swap(i, j int) {
+	q.commits[i], q.commits[j] = q.commits[j], q.commits[i]
+}
+
+func (q *readyQueue) Push(x any) {
+	q.commits = append(q.commits, x.(*storage.CommitData))
+}
+
+func (q *readyQueue) Pop() any {
+	last := q.commits[len(q.commits)-1]
+	q.commits = q.commits[:len(q.commits)-1]
+	return last
+}
@@ -0,0 +1,173 @@
+package merge
+
+import (
+	"strings"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// Markers of conflicting part of file
+const (
+	MARKER_OURS   = "<<<<<<<"
+	MARKER_SPLIT  = "======="
+	MARKER_THEIRS = ">>>>>>>"
+)
+
+// Replacement of base lines [start, end) with lines
+type hunk struct {
+	start int
+	end   int
+	lines []string
+}
+
+// Merge changes made to base in ours and theirs. Overlapping or adjacent changes that
+// differ are written between conflict markers. Lines keep their line breaks.
+func Lines(base, ours, theirs []string, oursLabel string, theirsLabel string) ([]string, bool) {
+	a := changes(base, ours)
+	b := changes(base, theirs)
+	out := make([]string, 0, len(base))
+	clean := true
+	pos := 0
+	for len(a) > 0 || len(b) > 0 {
+		// group of changes touching each other, starting with the first one
+		var start int
+		switch {
+		case len(b) == 0 || (len(a) > 0 && a[0].start <= b[0].start):
+			start = a[0].start
+		default:
+			start = b[0].start
+		}
+		end := start
+		var ga, gb []hunk
+		for {
+			if len(a) > 0 && a[0].start <= end {
+				end = max(end, a[0].end)
+				ga, a = append(ga, a[0]), a[1:]
+				continue
+			}
+			if len(b) > 0 && b[0].start <= end {
+				end = max(end, b[0].end)
+				gb, b = append(gb, b[0]), b[1:]
+				continue
+			}
+			break
+		}
+
+		out = append(out, base[pos:start]...)
+		oa := apply(base, start, end, ga)
+		ob := apply(base, start, end, gb)
+		switch {
+		case len(gb) == 0:
+			out = append(out, oa...)
+		case len(ga) == 0 || equal(oa, ob):
+			out = append(out, ob...)
+		default:
+			clean = false
+			out = append(out, MARKER_OURS+" "+oursLabel+"\n")
+			out = append(out, terminated(oa)...)
+			out = append(out, MARKER_SPLIT+"\n")
+			out = append(out, terminated(ob)...)
+			out = append(out, MARKER_THEIRS+" "+theirsLabel+"\n")
+		}
+		pos = end
+	}
+	out = append(out, base[pos:]...)
+	return out, clean
+}
+
+// Split text into lines keeping line breaks
+func SplitLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// Changes turning base into other, by line diff
+func changes(base, other []string) []hunk {
+	// every distinct line is replaced with one rune, so lines are diffed as characters
+	runes := make(map[string]rune)
+	encode := func(lines []string) []rune {
+		encoded := make([]rune, len(lines))
+		for i, line := range lines {
+			r, ok := runes[line]
+			if !ok {
+				r = rune(len(runes) + 1)
+				if r >= 0xD800 {
+					// skip surrogates, they are not valid runes
+					r += 0x800
+				}
+				runes[line] = r
+			}
+			encoded[i] = r
+		}
+		return encoded
+	}
+	dmp := diffmatchpatch.New()
+	diffs := dmp.DiffMainRunes(encode(base), encode(other), false)
+
+	hunks := make([]hunk, 0)
+	var current *hunk
+	pos, otherPos := 0, 0
+	for _, d := range diffs {
+		n := len([]rune(d.Text))
+		if d.Type == diffmatchpatch.DiffEqual {
+			if current != nil {
+				hunks = append(hunks, *current)
+				current = nil
+			}
+			pos += n
+			otherPos += n
+			continue
+		}
+		if current == nil {
+			current = &hunk{start: pos, end: pos}
+		}
+		if d.Type == diffmatchpatch.DiffDelete {
+			pos += n
+			current.end = pos
+		} else {
+			current.lines = append(current.lines, other[otherPos:otherPos+n]...)
+			otherPos += n
+		}
+	}
+	if current != nil {
+		hunks = append(hunks, *current)
+	}
+	return hunks
+}
+
+// Base lines [start, end) with changes applied
+func apply(base []string, start int, end int, hunks []hunk) []string {
+	out := make([]string, 0)
+	pos := start
+	for _, h := range hunks {
+		out = append(out, base[pos:h.start]...)
+		out = append(out, h.lines...)
+		pos = h.end
+	}
+	return append(out, base[pos:end]...)
+}
+
+// Lines with line break after the last one, so marker starts a new line
+func terminated(lines []string) []string {
+	if len(lines) == 0 || strings.HasSuffix(lines[len(lines)-1], "\n") {
+		return lines
+	}
+	out := append([]string{}, lines...)
+	out[len(out)-1] += "\n"
+	return out
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,142 @@
+package merge
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLines(t *testing.T) {
+	base := "a\nb\nc\nd\ne\n"
+	tests := []struct {
+		name   string
+		base   string
+		ours   string
+		theirs string
+		want   string
+		clean  bool
+	}{
+		{
+			name:   "no changes",
+			base:   base,
+			ours:   base,
+			theirs: base,
+			want:   base,
+			clean:  true,
+		},
+		{
+			name:   "only ours changed",
+			base:   base,
+			ours:   "a\nB\nc\nd\ne\n",
+			theirs: base,
+			want:   "a\nB\nc\nd\ne\n",
+			clean:  true,
+		},
+		{
+			name:   "only theirs changed",
+			base:   base,
+			ours:   base,
+			theirs: "a\nb\nc\nd\n",
+			want:   "a\nb\nc\nd\n",
+			clean:  true,
+		},
+		{
+			name:   "separate changes",
+			base:   base,
+			ours:   "A\nb\nc\nd\ne\n",
+			theirs: "a\nb\nc\nd\nE\n",
+			want:   "A\nb\nc\nd\nE\n",
+			clean:  true,
+		},
+		{
+			name:   "identical changes",
+			base:   base,
+			ours:   "a\nB\nc\nx\nd\ne\n",
+			theirs: "a\nB\nc\nx\nd\ne\n",
+			want:   "a\nB\nc\nx\nd\ne\n",
+			clean:  true,
+		},
+		{
+			name:   "adjacent changes",
+			base:   base,
+			ours:   "a\nB\nc\nd\ne\n",
+			theirs: "a\nb\nC\nd\ne\n",
+			want:   "a\n<<<<<<< ours\nB\nc\n=======\nb\nC\n>>>>>>> theirs\nd\ne\n",
+			clean:  false,
+		},
+		{
+			name:   "overlapping changes",
+			base:   base,
+			ours:   "a\nB\nC\nd\ne\n",
+			theirs: "a\nb\nX\nY\ne\n",
+			want:   "a\n<<<<<<< ours\nB\nC\nd\n=======\nb\nX\nY\n>>>>>>> theirs\ne\n",
+			clean:  false,
+		},
+		{
+			name:   "delete and change",
+			base:   base,
+			ours:   "a\nb\nd\ne\n",
+			theirs: "a\nb\nC\nd\ne\n",
+			want:   "a\nb\n<<<<<<< ours\n=======\nC\n>>>>>>> theirs\nd\ne\n",
+			clean:  false,
+		},
+		{
+			name:   "different insertions at end",
+			base:   base,
+			ours:   base + "x\n",
+			theirs: base + "y\n",
+			want:   base + "<<<<<<< ours\nx\n=======\ny\n>>>>>>> theirs\n",
+			clean:  false,
+		},
+		{
+			name:   "identical insertions at end",
+			base:   base,
+			ours:   base + "x\n",
+			theirs: base + "x\n",
+			want:   base + "x\n",
+			clean:  true,
+		},
+		{
+			name:   "no line break at end",
+			base:   "a\nb",
+			ours:   "a\nB",
+			theirs: "a\nC",
+			want:   "a\n<<<<<<< ours\nB\n=======\nC\n>>>>>>> theirs\n",
+			clean:  false,
+		},
+		{
+			name:   "empty base",
+			base:   "",
+			ours:   "x\n",
+			theirs: "",
+			want:   "x\n",
+			clean:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, clean := Lines(SplitLines(tt.base), SplitLines(tt.ours), SplitLines(tt.theirs), "ours", "theirs")
+			if joined := strings.Join(got, ""); joined != tt.want || clean != tt.clean {
+				t.Errorf("Lines() = %q, %v, want %q, %v", joined, clean, tt.want, tt.clean)
+			}
+		})
+	}
+}
+
+func TestSplitLines(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"", []string{}},
+		{"a", []string{"a"}},
+		{"a\n", []string{"a\n"}},
+		{"a\n\nb", []string{"a\n", "\n", "b"}},
+	}
+	for _, tt := range tests {
+		got := SplitLines(tt.text)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SplitLines(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
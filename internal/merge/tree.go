@@ -0,0 +1,292 @@
+package merge
+
+import (
+	"bytes"
+	"encoding/hex"
+	"mymodule/internal/object"
+	"mymodule/internal/storage"
+	"sort"
+	"strings"
+)
+
+// Result of applying changes to tree
+type Result struct {
+	Tree      []byte                    //Hash of resulting tree
+	Objects   map[string]*object.Object //New trees and blobs of result by hex hash
+	Conflicts []string                  //Paths changed on both sides in different ways, sorted
+}
+
+type merger struct {
+	s           *storage.Storage
+	theirs      []byte //Tree changes lead to
+	oursLabel   string
+	theirsLabel string
+	result      *Result
+	conflicts   map[string]bool
+}
+
+// Apply changes made from commit base to commit theirs, including added and deleted empty
+// files, to tree ours. File changed in ours too is merged by lines. Changes that can't be merged are
+// conflicts: file gets both versions between conflict markers labeled with oursLabel and
+// theirsLabel, or keeps version of ours if it can't hold markers. Nil base or theirs stands
+// for no commit, without files.
+func Apply(s *storage.Storage, ours []byte, base []byte, theirs []byte, oursLabel string, theirsLabel string) (*Result, error) {
+	cmp := object.Comparator{
+		GetFunction1: s.GetObject,
+		GetFunction2: s.GetObject,
+		EmptyFiles:   true,
+	}
+	changes, err := cmp.CompareCommits(base, theirs)
+	if err != nil {
+		return nil, err
+	}
+	baseTree, err := object.TreeOfCommit(s.GetObject, base)
+	if err != nil {
+		return nil, err
+	}
+	theirsTree, err := object.TreeOfCommit(s.GetObject, theirs)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &merger{
+		s:           s,
+		theirs:      theirsTree,
+		oursLabel:   oursLabel,
+		theirsLabel: theirsLabel,
+		result:      &Result{Objects: make(map[string]*object.Object)},
+		conflicts:   make(map[string]bool),
+	}
+	edits := make(map[string]*object.Child)
+	for _, change := range changes {
+		path := strings.TrimSuffix(string(change.FileName), "/")
+		b, err := m.entry(baseTree, path)
+		if err != nil {
+			return nil, err
+		}
+		o, err := m.entry(ours, path)
+		if err != nil {
+			return nil, err
+		}
+		t, err := m.entry(m.theirs, path)
+		if err != nil {
+			return nil, err
+		}
+		edits[path], err = m.resolve(path, b, o, t)
+		if err != nil {
+			return nil, err
+		}
+	}
+	m.result.Tree, err = m.edit("", ours, edits)
+	if err != nil {
+		return nil, err
+	}
+	for path := range m.conflicts {
+		m.result.Conflicts = append(m.result.Conflicts, path)
+	}
+	sort.Strings(m.result.Conflicts)
+	return m.result, nil
+}
+
+// Entry at path changed from b to t, given its version o in ours
+func (m *merger) resolve(path string, b, o, t *object.Child) (*object.Child, error) {
+	if same(o, t) || same(b, o) {
+		return t, nil
+	}
+	if o != nil && t != nil && o.Type == object.TypeBlob && t.Type == object.TypeBlob {
+		return m.mergeFiles(path, b, o, t)
+	}
+	switch {
+	case o == nil && t != nil && t.Type == object.TypeTree:
+		return t, nil
+	case o != nil && o.Type == object.TypeTree && (t == nil || t.Type == object.TypeTree):
+		// directory of ours stays, even if empty one was removed or added in theirs
+		return o, nil
+	case o == nil:
+		// deleted in ours, modified in theirs
+		m.conflicts[path] = true
+		return t, nil
+	}
+	// deleted in theirs while modified in ours, or file replaced with directory
+	m.conflicts[path] = true
+	return o, nil
+}
+
+// File changed on both sides: merge its mode and lines
+func (m *merger) mergeFiles(path string, b, o, t *object.Child) (*object.Child, error) {
+	if b != nil && b.Type != object.TypeBlob {
+		b = nil
+	}
+	mode := o.FileMode()
+	clean := true
+	switch {
+	case o.FileMode() == t.FileMode():
+	case b != nil && b.FileMode() == o.FileMode():
+		mode = t.FileMode()
+	case b != nil && b.FileMode() == t.FileMode():
+	default:
+		clean = false
+	}
+
+	var baseHash []byte
+	if b != nil {
+		baseHash = b.Hash
+	}
+	hash := o.Hash
+	switch {
+	case bytes.Equal(o.Hash, t.Hash), bytes.Equal(baseHash, t.Hash):
+	case bytes.Equal(baseHash, o.Hash):
+		hash = t.Hash
+	default:
+		symlink := o.FileMode() == object.ModeSymlink || t.FileMode() == object.ModeSymlink
+		merged, ok, err := m.mergeBlobs(baseHash, o.Hash, t.Hash, symlink)
+		if err != nil {
+			return nil, err
+		}
+		hash, clean = merged, clean && ok
+	}
+	if !clean {
+		m.conflicts[path] = true
+	}
+	return &object.Child{Type: object.TypeBlob, Hash: hash, Mode: mode}, nil
+}
+
+// Merge lines of blobs. Symlinks and binary files can't hold conflict markers, so ours
+// is kept for them.
+func (m *merger) mergeBlobs(base, ours, theirs []byte, symlink bool) ([]byte, bool, error) {
+	if symlink {
+		return ours, false, nil
+	}
+	texts := make([]string, 3)
+	for i, hash := range [][]byte{base, ours, theirs} {
+		if hash == nil {
+			continue
+		}
+		obj, err := m.s.GetObject(hash)
+		if err != nil {
+			return nil, false, err
+		}
+		blob, err := obj.ParseBlob()
+		if err != nil {
+			return nil, false, err
+		}
+		if bytes.IndexByte(blob.Data, 0) >= 0 {
+			return ours, false, nil
+		}
+		texts[i] = string(blob.Data)
+	}
+	lines, clean := Lines(SplitLines(texts[0]), SplitLines(texts[1]), SplitLines(texts[2]), m.oursLabel, m.theirsLabel)
+	blob := object.Blob{Data: []byte(strings.Join(lines, ""))}
+	hash, err := m.add(blob.CreateObject())
+	return hash, clean, err
+}
+
+// Tree with entries at paths relative to it replaced, nil entry removes path. Directory
+// left without entries is removed, unless theirs has it, and nil is returned for it.
+func (m *merger) edit(dir string, tree []byte, edits map[string]*object.Child) ([]byte, error) {
+	children := make(map[string]object.Child)
+	if tree != nil {
+		obj, err := m.s.GetObject(tree)
+		if err != nil {
+			return nil, err
+		}
+		t, err := obj.ParseTree()
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range t.Children {
+			children[string(c.Name)] = c
+		}
+	}
+
+	nested := make(map[string]map[string]*object.Child)
+	for path, c := range edits {
+		name, rest, ok := strings.Cut(path, "/")
+		if ok {
+			if nested[name] == nil {
+				nested[name] = make(map[string]*object.Child)
+			}
+			nested[name][rest] = c
+			continue
+		}
+		if c == nil {
+			delete(children, name)
+			continue
+		}
+		child := *c
+		child.Name = []byte(name)
+		children[name] = child
+	}
+	for name, sub := range nested {
+		path := name
+		if dir != "" {
+			path = dir + "/" + name
+		}
+		var subtree []byte
+		if c, ok := children[name]; ok {
+			if c.Type != object.TypeTree {
+				// file of ours where theirs has directory
+				for _, e := range sub {
+					if e != nil {
+						m.conflicts[path] = true
+					}
+				}
+				continue
+			}
+			subtree = c.Hash
+		}
+		hash, err := m.edit(path, subtree, sub)
+		if err != nil {
+			return nil, err
+		}
+		if hash == nil {
+			delete(children, name)
+			continue
+		}
+		children[name] = object.Child{Type: object.TypeTree, Name: []byte(name), Hash: hash}
+	}
+
+	if len(children) == 0 && dir != "" {
+		entry, err := m.entry(m.theirs, dir)
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil || entry.Type != object.TypeTree {
+			return nil, nil
+		}
+	}
+	t := object.Tree{Children: make([]object.Child, 0, len(children))}
+	for _, c := range children {
+		t.Children = append(t.Children, c)
+	}
+	obj, err := t.CreateObject()
+	if err != nil {
+		return nil, err
+	}
+	return m.add(obj)
+}
+
+// Keep new object of result, returns its hash
+func (m *merger) add(obj *object.Object) ([]byte, error) {
+	hash, err := obj.GetHash()
+	if err != nil {
+		return nil, err
+	}
+	m.result.Objects[hex.EncodeToString(hash)] = obj
+	return hash, nil
+}
+
+// Entry of tree at path, nil if tree or entry is missing
+func (m *merger) entry(tree []byte, path string) (*object.Child, error) {
+	if tree == nil {
+		return nil, nil
+	}
+	return m.s.FindEntry(tree, path)
+}
+
+func same(a, b *object.Child) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Type == b.Type && bytes.Equal(a.Hash, b.Hash) && a.FileMode() == b.FileMode()
+}
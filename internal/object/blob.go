@@ -8,7 +8,7 @@ type Blob struct {
 // Create object for blob
 func (b *Blob) CreateObject() *Object {
 	return &Object{
-		TypeBlob,
-		b.Data,
+		Type: TypeBlob,
+		Data: b.Data,
 	}
 }
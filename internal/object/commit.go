@@ -11,18 +11,47 @@ type Commit struct {
 	Author      []byte
 	Time        int64
 	Description []byte
+	Merges      [][]byte //References to other parents of merge commit
 }
 
 func (c *Commit) Serialize() (data []byte, err error) {
-	var b bytes.Buffer
-	encoder := gob.NewEncoder(&b)
-	err = encoder.Encode(c)
-	data = b.Bytes()
+	data = appendField(data, tagCommitOrigin, c.Origin)
+	data = appendField(data, tagCommitTree, c.Tree)
+	data = appendField(data, tagCommitAuthor, c.Author)
+	data = appendIntField(data, tagCommitTime, c.Time)
+	data = appendField(data, tagCommitDescription, c.Description)
+	for _, m := range c.Merges {
+		data = appendField(data, tagCommitMerge, m)
+	}
 	return
 }
 
-// Deserialize data into tree
+// Deserialize data into commit
 func DeserializeCommit(data []byte) (*Commit, error) {
+	var commit Commit
+	err := readFields(data, func(tag uint64, value []byte) error {
+		var err error
+		switch tag {
+		case tagCommitOrigin:
+			commit.Origin = clone(value)
+		case tagCommitTree:
+			commit.Tree = clone(value)
+		case tagCommitAuthor:
+			commit.Author = clone(value)
+		case tagCommitTime:
+			commit.Time, err = readInt(value)
+		case tagCommitDescription:
+			commit.Description = clone(value)
+		case tagCommitMerge:
+			commit.Merges = append(commit.Merges, clone(value))
+		}
+		return err
+	})
+	return &commit, err
+}
+
+// Deserialize commit written with gob before versioned encoding
+func deserializeGobCommit(data []byte) (*Commit, error) {
 	var commit Commit
 	decoder := gob.NewDecoder(bytes.NewReader(data))
 	err := decoder.Decode(&commit)
@@ -35,7 +64,7 @@ func (c *Commit) CreateObject() (*Object, error) {
 		return nil, err
 	}
 	return &Object{
-		TypeCommit,
-		data,
+		Type: TypeCommit,
+		Data: data,
 	}, nil
 }
@@ -6,18 +6,24 @@ import (
 	"github.com/sergi/go-diff/diffmatchpatch"
 )
 
+// Status of changed file
 const (
-	ActionInsert = iota
-	ActionDelete
+	StatusModified = "modified"
+	StatusAdded    = "added"
+	StatusDeleted  = "deleted"
 )
 
 type Comparator struct {
 	GetFunction1 func([]byte) (*Object, error)
 	GetFunction2 func([]byte) (*Object, error)
+	EmptyFiles   bool //Report added and deleted files without lines, diff and status skip them
 }
 
 type FileChange struct {
 	FileName []byte
+	Status   string //StatusModified, StatusAdded or StatusDeleted
+	OldMode  uint32 //Mode before change, 0 for added file
+	NewMode  uint32 //Mode after change, 0 for deleted file
 	Changes  []diffmatchpatch.Diff
 }
 
@@ -62,22 +68,21 @@ func (cmp *Comparator) CompareTrees(hash1 []byte, hash2 []byte) ([]*FileChange,
 					if err != nil {
 						return fileChanges, err
 					}
-					if len(diffs) > 0 {
+					if len(diffs) > 0 || cmp.EmptyFiles {
 						fileChanges = append(fileChanges, &FileChange{
 							FileName: c.Name,
+							Status:   StatusAdded,
+							NewMode:  c.FileMode(),
 							Changes:  diffs,
 						})
 					}
 
 				case TypeTree:
-					changes, err := cmp.CompareTrees(nil, c.Hash)
+					changes, err := cmp.compareSubtrees(c.Name, nil, c.Hash)
 					if err != nil {
 						return fileChanges, err
 					}
-					for _, change := range changes {
-						change.FileName = bytes.Join([][]byte{c.Name, []byte("/"), change.FileName}, []byte(""))
-						fileChanges = append(fileChanges, change)
-					}
+					fileChanges = append(fileChanges, changes...)
 				}
 
 			}
@@ -91,21 +96,20 @@ func (cmp *Comparator) CompareTrees(hash1 []byte, hash2 []byte) ([]*FileChange,
 					if err != nil {
 						return fileChanges, err
 					}
-					if len(diffs) > 0 {
+					if len(diffs) > 0 || cmp.EmptyFiles {
 						fileChanges = append(fileChanges, &FileChange{
 							FileName: c.Name,
+							Status:   StatusDeleted,
+							OldMode:  c.FileMode(),
 							Changes:  diffs,
 						})
 					}
 				case TypeTree:
-					changes, err := cmp.CompareTrees(c.Hash, nil)
+					changes, err := cmp.compareSubtrees(c.Name, c.Hash, nil)
 					if err != nil {
 						return fileChanges, err
 					}
-					for _, change := range changes {
-						change.FileName = bytes.Join([][]byte{c.Name, []byte("/"), change.FileName}, []byte(""))
-						fileChanges = append(fileChanges, change)
-					}
+					fileChanges = append(fileChanges, changes...)
 				}
 
 			}
@@ -126,22 +130,22 @@ func (cmp *Comparator) CompareTrees(hash1 []byte, hash2 []byte) ([]*FileChange,
 						if err != nil {
 							return fileChanges, err
 						}
-						if len(diffs) > 0 {
+						if changed(diffs) || c1.FileMode() != c2.FileMode() {
 							fileChanges = append(fileChanges, &FileChange{
 								FileName: c1.Name,
+								Status:   StatusModified,
+								OldMode:  c1.FileMode(),
+								NewMode:  c2.FileMode(),
 								Changes:  diffs,
 							})
 						}
 
 					case TypeTree:
-						changes, err := cmp.CompareTrees(c1.Hash, c2.Hash)
+						changes, err := cmp.compareSubtrees(c1.Name, c1.Hash, c2.Hash)
 						if err != nil {
 							return fileChanges, err
 						}
-						for _, change := range changes {
-							change.FileName = bytes.Join([][]byte{c1.Name, []byte("/"), change.FileName}, []byte(""))
-							fileChanges = append(fileChanges, change)
-						}
+						fileChanges = append(fileChanges, changes...)
 
 					}
 
@@ -152,21 +156,20 @@ func (cmp *Comparator) CompareTrees(hash1 []byte, hash2 []byte) ([]*FileChange,
 						if err != nil {
 							return fileChanges, err
 						}
-						if len(diffs) > 0 {
+						if len(diffs) > 0 || cmp.EmptyFiles {
 							fileChanges = append(fileChanges, &FileChange{
 								FileName: c1.Name,
+								Status:   StatusDeleted,
+								OldMode:  c1.FileMode(),
 								Changes:  diffs,
 							})
 						}
 					case TypeTree:
-						changes, err := cmp.CompareTrees(c1.Hash, nil)
+						changes, err := cmp.compareSubtrees(c1.Name, c1.Hash, nil)
 						if err != nil {
 							return fileChanges, err
 						}
-						for _, change := range changes {
-							change.FileName = bytes.Join([][]byte{c1.Name, []byte("/"), change.FileName}, []byte(""))
-							fileChanges = append(fileChanges, change)
-						}
+						fileChanges = append(fileChanges, changes...)
 					}
 
 					switch c2.Type {
@@ -175,21 +178,20 @@ func (cmp *Comparator) CompareTrees(hash1 []byte, hash2 []byte) ([]*FileChange,
 						if err != nil {
 							return fileChanges, err
 						}
-						if len(diffs) > 0 {
+						if len(diffs) > 0 || cmp.EmptyFiles {
 							fileChanges = append(fileChanges, &FileChange{
 								FileName: c2.Name,
+								Status:   StatusAdded,
+								NewMode:  c2.FileMode(),
 								Changes:  diffs,
 							})
 						}
 					case TypeTree:
-						changes, err := cmp.CompareTrees(nil, c2.Hash)
+						changes, err := cmp.compareSubtrees(c2.Name, nil, c2.Hash)
 						if err != nil {
 							return fileChanges, err
 						}
-						for _, change := range changes {
-							change.FileName = bytes.Join([][]byte{c2.Name, []byte("/"), change.FileName}, []byte(""))
-							fileChanges = append(fileChanges, change)
-						}
+						fileChanges = append(fileChanges, changes...)
 					}
 				}
 				break
@@ -203,21 +205,20 @@ func (cmp *Comparator) CompareTrees(hash1 []byte, hash2 []byte) ([]*FileChange,
 				if err != nil {
 					return fileChanges, err
 				}
-				if len(diffs) > 0 {
+				if len(diffs) > 0 || cmp.EmptyFiles {
 					fileChanges = append(fileChanges, &FileChange{
 						FileName: c1.Name,
+						Status:   StatusDeleted,
+						OldMode:  c1.FileMode(),
 						Changes:  diffs,
 					})
 				}
 			case TypeTree:
-				changes, err := cmp.CompareTrees(c1.Hash, nil)
+				changes, err := cmp.compareSubtrees(c1.Name, c1.Hash, nil)
 				if err != nil {
 					return fileChanges, err
 				}
-				for _, change := range changes {
-					change.FileName = bytes.Join([][]byte{c1.Name, []byte("/"), change.FileName}, []byte(""))
-					fileChanges = append(fileChanges, change)
-				}
+				fileChanges = append(fileChanges, changes...)
 			}
 		}
 	}
@@ -237,27 +238,64 @@ func (cmp *Comparator) CompareTrees(hash1 []byte, hash2 []byte) ([]*FileChange,
 				if err != nil {
 					return fileChanges, err
 				}
-				if len(diffs) > 0 {
+				if len(diffs) > 0 || cmp.EmptyFiles {
 					fileChanges = append(fileChanges, &FileChange{
 						FileName: c2.Name,
+						Status:   StatusAdded,
+						NewMode:  c2.FileMode(),
 						Changes:  diffs,
 					})
 				}
 			case TypeTree:
-				changes, err := cmp.CompareTrees(nil, c2.Hash)
+				changes, err := cmp.compareSubtrees(c2.Name, nil, c2.Hash)
 				if err != nil {
 					return fileChanges, err
 				}
-				for _, change := range changes {
-					change.FileName = bytes.Join([][]byte{c2.Name, []byte("/"), change.FileName}, []byte(""))
-					fileChanges = append(fileChanges, change)
-				}
+				fileChanges = append(fileChanges, changes...)
 			}
 		}
 	}
 	return fileChanges, nil
 }
 
+// Compare subtrees of directory name and prefix changes with it. Directory added or removed
+// as a whole with no entries in it is reported itself, named with trailing "/".
+func (cmp *Comparator) compareSubtrees(name []byte, hash1 []byte, hash2 []byte) ([]*FileChange, error) {
+	changes, err := cmp.CompareTrees(hash1, hash2)
+	if err != nil {
+		return nil, err
+	}
+	for _, change := range changes {
+		change.FileName = bytes.Join([][]byte{name, []byte("/"), change.FileName}, []byte(""))
+	}
+	if len(changes) > 0 || (hash1 != nil && hash2 != nil) {
+		return changes, nil
+	}
+
+	change := &FileChange{
+		FileName: append(append([]byte{}, name...), '/'),
+		Status:   StatusAdded,
+		NewMode:  ModeDirectory,
+	}
+	getFunction, hash := cmp.GetFunction2, hash2
+	if hash2 == nil {
+		change.Status, change.OldMode, change.NewMode = StatusDeleted, ModeDirectory, 0
+		getFunction, hash = cmp.GetFunction1, hash1
+	}
+	obj, err := getFunction(hash)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := obj.ParseTree()
+	if err != nil {
+		return nil, err
+	}
+	if len(tree.Children) == 0 {
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
 func (cmp *Comparator) CompareBlobs(hash1 []byte, hash2 []byte) ([]diffmatchpatch.Diff, error) {
 	diffs := make([]diffmatchpatch.Diff, 0)
 	if bytes.Equal(hash1, hash2) {
@@ -303,28 +341,62 @@ func (cmp *Comparator) CompareBlobs(hash1 []byte, hash2 []byte) ([]diffmatchpatc
 	return diffs, nil
 }
 
+// Check that trees have the same files. Trees with different hashes still match when
+// their objects are written in different encodings, as in repositories with gob encoded
+// objects, so files are compared by content.
+func (cmp *Comparator) SameTrees(hash1 []byte, hash2 []byte) (bool, error) {
+	if bytes.Equal(hash1, hash2) {
+		return true, nil
+	}
+	changes, err := cmp.CompareTrees(hash1, hash2)
+	if err != nil {
+		return false, err
+	}
+	return len(changes) == 0, nil
+}
+
+// Diffs have changed lines. Blobs with the same content but different hashes give
+// a single equal diff.
+func changed(diffs []diffmatchpatch.Diff) bool {
+	for _, d := range diffs {
+		if d.Type != diffmatchpatch.DiffEqual {
+			return true
+		}
+	}
+	return false
+}
+
+// Compare trees of commits. Nil hash stands for no commit, with no files.
 func (cmp *Comparator) CompareCommits(hash1 []byte, hash2 []byte) ([]*FileChange, error) {
 	fileChanges := make([]*FileChange, 0)
 	if bytes.Equal(hash1, hash2) {
 		return fileChanges, nil
 	}
-	obj1, err := cmp.GetFunction1(hash1)
+	tree1, err := TreeOfCommit(cmp.GetFunction1, hash1)
 	if err != nil {
 		return fileChanges, err
 	}
-	obj2, err := cmp.GetFunction2(hash2)
+	tree2, err := TreeOfCommit(cmp.GetFunction2, hash2)
 	if err != nil {
 		return fileChanges, err
 	}
-	c1, err := obj1.ParseCommit()
+	fileChanges, err = cmp.CompareTrees(tree1, tree2)
+
+	return fileChanges, err
+}
+
+// Tree of commit read with getFunction, nil for nil hash
+func TreeOfCommit(getFunction func([]byte) (*Object, error), hash []byte) ([]byte, error) {
+	if hash == nil {
+		return nil, nil
+	}
+	obj, err := getFunction(hash)
 	if err != nil {
-		return fileChanges, err
+		return nil, err
 	}
-	c2, err := obj2.ParseCommit()
+	commit, err := obj.ParseCommit()
 	if err != nil {
-		return fileChanges, err
+		return nil, err
 	}
-	fileChanges, err = cmp.CompareTrees(c1.Tree, c2.Tree)
-
-	return fileChanges, err
+	return commit.Tree, nil
 }
@@ -0,0 +1,167 @@
+package object
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"sort"
+	"testing"
+)
+
+// Objects by hash, as stored in database
+type objectStore map[string]*Object
+
+func (st objectStore) get(hash []byte) (*Object, error) {
+	obj, ok := st[string(hash)]
+	if !ok {
+		return nil, fmt.Errorf("object %x not found", hash)
+	}
+	return obj, nil
+}
+
+func (st objectStore) add(t *testing.T, obj *Object) []byte {
+	t.Helper()
+	hash, err := obj.GetHash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	st[string(hash)] = obj
+	return hash
+}
+
+// Object as written by versions before versioned encoding
+func legacyObject(t *testing.T, objType uint, value any) *Object {
+	t.Helper()
+	data, ok := value.([]byte)
+	if !ok {
+		var b bytes.Buffer
+		if err := gob.NewEncoder(&b).Encode(value); err != nil {
+			t.Fatal(err)
+		}
+		data = b.Bytes()
+	}
+	var b bytes.Buffer
+	if err := gob.NewEncoder(&b).Encode(Object{Type: objType, Data: data}); err != nil {
+		t.Fatal(err)
+	}
+	zipped, err := Zip(b.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj, err := DeserializeObject(zipped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return obj
+}
+
+// Tree of files (path "dir/name" to content), with objects in encoding of legacy or current
+func buildTree(t *testing.T, st objectStore, files map[string]string, legacy bool) []byte {
+	t.Helper()
+	children := make([]Child, 0)
+	dirs := make(map[string]map[string]string)
+	for path, content := range files {
+		dir, name, nested := bytes.Cut([]byte(path), []byte("/"))
+		if nested {
+			if dirs[string(dir)] == nil {
+				dirs[string(dir)] = make(map[string]string)
+			}
+			dirs[string(dir)][string(name)] = content
+			continue
+		}
+		blob := (&Blob{Data: []byte(content)}).CreateObject()
+		if legacy {
+			blob = legacyObject(t, TypeBlob, []byte(content))
+		}
+		children = append(children, Child{Type: TypeBlob, Name: []byte(path), Hash: st.add(t, blob)})
+	}
+	for dir, dirFiles := range dirs {
+		children = append(children, Child{Type: TypeTree, Name: []byte(dir), Hash: buildTree(t, st, dirFiles, legacy)})
+	}
+	sort.Slice(children, func(i, j int) bool {
+		return bytes.Compare(children[i].Name, children[j].Name) < 0
+	})
+	tree := &Tree{Children: children}
+	if legacy {
+		return st.add(t, legacyObject(t, TypeTree, tree))
+	}
+	obj, err := tree.CreateObject()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return st.add(t, obj)
+}
+
+func TestCompareLegacyTrees(t *testing.T) {
+	base := map[string]string{"a.txt": "hello\n", "d/b.txt": "x\ny\n", "d/c": ""}
+	tests := []struct {
+		name    string
+		files   map[string]string
+		changes []string
+	}{
+		{"same files", base, nil},
+		{"modified file", map[string]string{"a.txt": "hello\n", "d/b.txt": "x\nz\n", "d/c": ""}, []string{"modified d/b.txt"}},
+		{"added and deleted files", map[string]string{"a.txt": "hello\n", "d/b.txt": "x\ny\n", "e": "new\n"}, []string{"deleted d/c", "added e"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := make(objectStore)
+			legacyTree := buildTree(t, st, base, true)
+			tree := buildTree(t, st, tt.files, false)
+			if bytes.Equal(legacyTree, tree) {
+				t.Fatalf("legacy and current trees have the same hash")
+			}
+			cmp := Comparator{GetFunction1: st.get, GetFunction2: st.get, EmptyFiles: true}
+
+			changes, err := cmp.CompareTrees(legacyTree, tree)
+			if err != nil {
+				t.Fatalf("CompareTrees() error: %v", err)
+			}
+			got := make([]string, 0)
+			for _, c := range changes {
+				got = append(got, c.Status+" "+string(c.FileName))
+			}
+			if fmt.Sprint(got) != fmt.Sprint(append([]string{}, tt.changes...)) {
+				t.Errorf("CompareTrees() = %v, want %v", got, tt.changes)
+			}
+
+			same, err := cmp.SameTrees(legacyTree, tree)
+			if err != nil {
+				t.Fatalf("SameTrees() error: %v", err)
+			}
+			if same != (len(tt.changes) == 0) {
+				t.Errorf("SameTrees() = %v, want %v", same, len(tt.changes) == 0)
+			}
+		})
+	}
+}
+
+func TestCompareEmptyFiles(t *testing.T) {
+	st := make(objectStore)
+	before := buildTree(t, st, map[string]string{"a.txt": "a\n", "old": ""}, false)
+	after := buildTree(t, st, map[string]string{"a.txt": "a\n", "new": ""}, false)
+	tests := []struct {
+		name       string
+		emptyFiles bool
+		changes    []string
+	}{
+		{"skipped by default", false, []string{}},
+		{"reported on request", true, []string{"deleted old", "added new"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmp := Comparator{GetFunction1: st.get, GetFunction2: st.get, EmptyFiles: tt.emptyFiles}
+			changes, err := cmp.CompareTrees(before, after)
+			if err != nil {
+				t.Fatalf("CompareTrees() error: %v", err)
+			}
+			got := make([]string, 0)
+			for _, c := range changes {
+				got = append(got, c.Status+" "+string(c.FileName))
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.changes) {
+				t.Errorf("CompareTrees() = %v, want %v", got, tt.changes)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package object
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Binary encoding of objects.
+//
+// Object:  "VCS" | version byte | type byte | payload
+// Blob:    payload is file content
+// Tree:    payload is sequence of entries, each is uvarint length followed by fields
+// Commit:  payload is sequence of fields
+//
+// Field is uvarint tag, uvarint length and value. Readers skip unknown tags, so new
+// optional fields can be added without changing version. Objects written before this
+// encoding existed are gob streams and are recognized by missing magic.
+
+// Magic prefix of encoded objects
+var magic = []byte("VCS")
+
+// Version of object encoding written by this program
+const EncodingVersion = 1
+
+// Tags of tree entry fields
+const (
+	tagChildType = iota + 1
+	tagChildName
+	tagChildHash
+	tagChildMode //Omitted for regular files and directories
+)
+
+// Tags of commit fields
+const (
+	tagCommitOrigin = iota + 1
+	tagCommitTree
+	tagCommitAuthor
+	tagCommitTime
+	tagCommitDescription
+	tagCommitMerge //Repeated for every additional parent
+)
+
+// Check if serialized object uses versioned encoding
+func isEncoded(data []byte) bool {
+	return len(data) >= len(magic)+2 && bytes.Equal(data[:len(magic)], magic)
+}
+
+// Encode object header and payload
+func encodeObject(t uint, payload []byte) []byte {
+	b := make([]byte, 0, len(magic)+2+len(payload))
+	b = append(b, magic...)
+	b = append(b, EncodingVersion, byte(t))
+	return append(b, payload...)
+}
+
+// Decode object header, returns type and payload
+func decodeObject(data []byte) (uint, []byte, error) {
+	if !isEncoded(data) {
+		return 0, nil, errors.New("Object has unknown encoding")
+	}
+	version := data[len(magic)]
+	if version > EncodingVersion {
+		return 0, nil, fmt.Errorf("Object encoding version %d is not supported", version)
+	}
+	return uint(data[len(magic)+1]), data[len(magic)+2:], nil
+}
+
+func appendField(b []byte, tag uint64, value []byte) []byte {
+	b = binary.AppendUvarint(b, tag)
+	b = binary.AppendUvarint(b, uint64(len(value)))
+	return append(b, value...)
+}
+
+func appendUintField(b []byte, tag uint64, value uint64) []byte {
+	return appendField(b, tag, binary.AppendUvarint(nil, value))
+}
+
+func appendIntField(b []byte, tag uint64, value int64) []byte {
+	return appendField(b, tag, binary.AppendVarint(nil, value))
+}
+
+// Call fn for every field of data
+func readFields(data []byte, fn func(tag uint64, value []byte) error) error {
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errors.New("Malformed field tag")
+		}
+		data = data[n:]
+		length, n := binary.Uvarint(data)
+		if n <= 0 || length > uint64(len(data)-n) {
+			return errors.New("Malformed field length")
+		}
+		data = data[n:]
+		err := fn(tag, data[:length])
+		if err != nil {
+			return err
+		}
+		data = data[length:]
+	}
+	return nil
+}
+
+func readUint(value []byte) (uint64, error) {
+	v, n := binary.Uvarint(value)
+	if n <= 0 {
+		return 0, errors.New("Malformed number")
+	}
+	return v, nil
+}
+
+func readInt(value []byte) (int64, error) {
+	v, n := binary.Varint(value)
+	if n <= 0 {
+		return 0, errors.New("Malformed number")
+	}
+	return v, nil
+}
+
+// Copy of value, so decoded objects don't share memory with buffer
+func clone(value []byte) []byte {
+	return append([]byte{}, value...)
+}
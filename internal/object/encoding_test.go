@@ -0,0 +1,268 @@
+package object
+
+import (
+	"bytes"
+	"encoding/gob"
+	"reflect"
+	"testing"
+)
+
+func TestTreeRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		tree *Tree
+		want *Tree
+	}{
+		{
+			name: "empty",
+			tree: &Tree{Children: []Child{}},
+			want: &Tree{Children: []Child{}},
+		},
+		{
+			name: "children are sorted and get default modes",
+			tree: &Tree{Children: []Child{
+				{Type: TypeBlob, Name: []byte("b"), Hash: []byte{2}},
+				{Type: TypeTree, Name: []byte("a"), Hash: []byte{1}},
+			}},
+			want: &Tree{Children: []Child{
+				{Type: TypeTree, Name: []byte("a"), Hash: []byte{1}, Mode: ModeDirectory},
+				{Type: TypeBlob, Name: []byte("b"), Hash: []byte{2}, Mode: ModeRegular},
+			}},
+		},
+		{
+			name: "executable and symlink modes are kept",
+			tree: &Tree{Children: []Child{
+				{Type: TypeBlob, Name: []byte("run.sh"), Hash: []byte{3}, Mode: ModeExecutable},
+				{Type: TypeBlob, Name: []byte("link"), Hash: []byte{4}, Mode: ModeSymlink},
+			}},
+			want: &Tree{Children: []Child{
+				{Type: TypeBlob, Name: []byte("link"), Hash: []byte{4}, Mode: ModeSymlink},
+				{Type: TypeBlob, Name: []byte("run.sh"), Hash: []byte{3}, Mode: ModeExecutable},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := tt.tree.Serialize()
+			if err != nil {
+				t.Fatalf("Serialize() error: %v", err)
+			}
+			got, err := DeserializeTree(data)
+			if err != nil {
+				t.Fatalf("DeserializeTree() error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DeserializeTree() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTreeHashIgnoresOrder(t *testing.T) {
+	a := Child{Type: TypeBlob, Name: []byte("a"), Hash: []byte{1}}
+	b := Child{Type: TypeBlob, Name: []byte("b"), Hash: []byte{2}}
+	data1, err := (&Tree{Children: []Child{a, b}}).Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data2, err := (&Tree{Children: []Child{b, a}}).Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data1, data2) {
+		t.Errorf("trees with children in different order serialize differently")
+	}
+	// explicit default mode is not written, so hash matches tree without modes
+	a.Mode = ModeRegular
+	data3, err := (&Tree{Children: []Child{a, b}}).Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data1, data3) {
+		t.Errorf("default mode changes serialized tree")
+	}
+}
+
+func TestCommitRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		commit *Commit
+	}{
+		{
+			name:   "initial commit",
+			commit: &Commit{Origin: []byte{}, Tree: []byte{1, 2}, Author: []byte{}, Time: 1700000000, Description: []byte("Initial commit")},
+		},
+		{
+			name: "merge commit",
+			commit: &Commit{Origin: []byte{3}, Tree: []byte{4}, Author: []byte("a <a@x>"), Time: 1,
+				Description: []byte("merge\n\nbody"), Merges: [][]byte{{5}, {6}}},
+		},
+		{
+			name:   "negative time",
+			commit: &Commit{Origin: []byte{7}, Tree: []byte{8}, Author: []byte("b"), Time: -86400, Description: []byte{}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := tt.commit.Serialize()
+			if err != nil {
+				t.Fatalf("Serialize() error: %v", err)
+			}
+			got, err := DeserializeCommit(data)
+			if err != nil {
+				t.Fatalf("DeserializeCommit() error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.commit) {
+				t.Errorf("DeserializeCommit() = %+v, want %+v", got, tt.commit)
+			}
+		})
+	}
+}
+
+func TestObjectRoundTrip(t *testing.T) {
+	tree, err := (&Tree{Children: []Child{{Type: TypeBlob, Name: []byte("f"), Hash: []byte{1}}}}).CreateObject()
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit, err := (&Commit{Tree: []byte{1}, Time: 2}).CreateObject()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		obj  *Object
+	}{
+		{"blob", (&Blob{Data: []byte("content\n")}).CreateObject()},
+		{"empty blob", (&Blob{Data: []byte{}}).CreateObject()},
+		{"blob looking like gob", (&Blob{Data: []byte{0x1f, 0xff, 0x81}}).CreateObject()},
+		{"tree", tree},
+		{"commit", commit},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hash, data, err := tt.obj.GetData()
+			if err != nil {
+				t.Fatalf("GetData() error: %v", err)
+			}
+			got, err := DeserializeObject(data)
+			if err != nil {
+				t.Fatalf("DeserializeObject() error: %v", err)
+			}
+			if got.IsLegacy() || got.Type != tt.obj.Type || !bytes.Equal(got.Data, tt.obj.Data) {
+				t.Errorf("DeserializeObject() = %+v, want %+v", got, tt.obj)
+			}
+			gotHash, err := got.GetHash()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(gotHash, hash) {
+				t.Errorf("hash changed after round trip: %x, want %x", gotHash, hash)
+			}
+		})
+	}
+}
+
+func TestLegacyObject(t *testing.T) {
+	var treeData bytes.Buffer
+	legacyTree := Tree{Children: []Child{{Type: TypeBlob, Name: []byte("f"), Hash: []byte{1}}}}
+	if err := gob.NewEncoder(&treeData).Encode(legacyTree); err != nil {
+		t.Fatal(err)
+	}
+	var objData bytes.Buffer
+	if err := gob.NewEncoder(&objData).Encode(Object{Type: TypeTree, Data: treeData.Bytes()}); err != nil {
+		t.Fatal(err)
+	}
+	zipped, err := Zip(objData.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	obj, err := DeserializeObject(zipped)
+	if err != nil {
+		t.Fatalf("DeserializeObject() error: %v", err)
+	}
+	if !obj.IsLegacy() {
+		t.Errorf("gob object is not recognized as legacy")
+	}
+	tree, err := obj.ParseTree()
+	if err != nil {
+		t.Fatalf("ParseTree() error: %v", err)
+	}
+	want := []Child{{Type: TypeBlob, Name: []byte("f"), Hash: []byte{1}, Mode: ModeRegular}}
+	if !reflect.DeepEqual(tree.Children, want) {
+		t.Errorf("ParseTree() = %+v, want %+v", tree.Children, want)
+	}
+	// legacy object keeps hash of its gob encoding
+	hash, err := obj.GetHash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(hash, CalculateHash(objData.Bytes())) {
+		t.Errorf("legacy object hash changed")
+	}
+}
+
+func TestDecodeMalformed(t *testing.T) {
+	tests := []struct {
+		name   string
+		decode func() error
+	}{
+		{"tree entry longer than data", func() error {
+			_, err := DeserializeTree([]byte{10, 1})
+			return err
+		}},
+		{"tree field longer than entry", func() error {
+			_, err := DeserializeTree([]byte{3, tagChildName, 5, 'a'})
+			return err
+		}},
+		{"commit time is not a number", func() error {
+			_, err := DeserializeCommit([]byte{tagCommitTime, 1, 0x80})
+			return err
+		}},
+		{"newer object version", func() error {
+			_, _, err := decodeObject([]byte{'V', 'C', 'S', EncodingVersion + 1, TypeBlob})
+			return err
+		}},
+		{"missing magic", func() error {
+			_, _, err := decodeObject([]byte{'X', 'C', 'S', EncodingVersion, TypeBlob})
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.decode(); err == nil {
+				t.Errorf("decoding succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestCheckEntryName(t *testing.T) {
+	tests := []struct {
+		name  string
+		valid bool
+	}{
+		{"file.txt", true},
+		{".hidden", true},
+		{"...", true},
+		{"", false},
+		{".", false},
+		{"..", false},
+		{RepositoryDir, false},
+		{"a/b", false},
+		{"../x", false},
+		{"a\x00b", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckEntryName([]byte(tt.name))
+			if (err == nil) != tt.valid {
+				t.Errorf("CheckEntryName(%q) = %v, want valid %v", tt.name, err, tt.valid)
+			}
+			tree := &Tree{Children: []Child{{Type: TypeBlob, Name: []byte(tt.name), Hash: []byte{1}}}}
+			if _, err := tree.Serialize(); (err == nil) != tt.valid {
+				t.Errorf("Serialize() with name %q = %v, want valid %v", tt.name, err, tt.valid)
+			}
+		})
+	}
+}
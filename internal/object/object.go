@@ -17,6 +17,8 @@ const (
 type Object struct {
 	Type uint
 	Data []byte
+
+	legacy bool //Object and its Data are gob encoded, as written by old versions
 }
 
 func TypeToString(t uint) string {
@@ -53,34 +55,65 @@ func (o *Object) GetData() (hash []byte, data []byte, err error) {
 	return
 }
 
-// Serialize object
+// Get zipped serialized object, as it is stored in database
+func (o *Object) GetZippedData() ([]byte, error) {
+	b, err := o.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	return Zip(b)
+}
+
+// Serialize object. Legacy objects are serialized with gob, so their hash stays the same.
 func (o *Object) Serialize() ([]byte, error) {
-	var b bytes.Buffer
-	encoder := gob.NewEncoder(&b)
-	err := encoder.Encode(o)
-	return b.Bytes(), err
+	if o.legacy {
+		var b bytes.Buffer
+		encoder := gob.NewEncoder(&b)
+		err := encoder.Encode(o)
+		return b.Bytes(), err
+	}
+	return encodeObject(o.Type, o.Data), nil
 }
 
 // Deserialize data into object
 func DeserializeObject(data []byte) (*Object, error) {
-	var obj Object
 	unzipData, err := Unzip(data)
 	if err != nil {
 		return nil, err
 	}
+	if isEncoded(unzipData) {
+		t, payload, err := decodeObject(unzipData)
+		if err != nil {
+			return nil, err
+		}
+		return &Object{
+			Type: t,
+			Data: payload,
+		}, nil
+	}
+	var obj Object
 	decoder := gob.NewDecoder(bytes.NewReader(unzipData))
 	err = decoder.Decode(&obj)
 	if err != nil {
 		return nil, err
 	}
+	obj.legacy = true
 	return &obj, nil
 }
 
+// Object is stored in gob encoding of old versions
+func (o *Object) IsLegacy() bool {
+	return o.legacy
+}
+
 // Unpack object into tree if it is possible
 func (o *Object) ParseTree() (*Tree, error) {
 	if o.Type != TypeTree {
 		return nil, errors.New("Object is not tree")
 	}
+	if o.legacy {
+		return deserializeGobTree(o.Data)
+	}
 	return DeserializeTree(o.Data)
 }
 
@@ -97,6 +130,9 @@ func (o *Object) ParseCommit() (*Commit, error) {
 	if o.Type != TypeCommit {
 		return nil, errors.New("Object is not commit")
 	}
+	if o.legacy {
+		return deserializeGobCommit(o.Data)
+	}
 	return DeserializeCommit(o.Data)
 
 }
@@ -2,36 +2,148 @@ package object
 
 import (
 	"bytes"
+	"encoding/binary"
 	"encoding/gob"
+	"errors"
+	"fmt"
+	"sort"
 )
 
+// Name of repository directory inside working tree, it can't be tracked
+const RepositoryDir = ".vcs"
+
 // Tree elem that have children (dir for example)
 type Tree struct {
 	Children []Child
 }
 
+// Modes of tree entries, the same numbers as in git
+const (
+	ModeRegular    = 0o100644
+	ModeExecutable = 0o100755
+	ModeSymlink    = 0o120000 //Blob holds link target
+	ModeDirectory  = 0o40000
+)
+
 // Child of Tree.
 type Child struct {
 	Type uint   //TypeBlob or TypeTree.
 	Name []byte //FileName or TreeName.
 	Hash []byte //Hash of child object.
+	Mode uint32 //One of Mode constants, 0 means default mode for Type.
+}
+
+// Mode of entry of type when it is not stored: regular file or directory
+func DefaultMode(t uint) uint32 {
+	if t == TypeTree {
+		return ModeDirectory
+	}
+	return ModeRegular
+}
+
+// Mode of child with default applied
+func (c *Child) FileMode() uint32 {
+	if c.Mode == 0 {
+		return DefaultMode(c.Type)
+	}
+	return c.Mode
+}
+
+// Check that name can be used as name of tree entry inside working tree: it is not empty,
+// ".", ".." or RepositoryDir and has no "/" or NUL in it
+func CheckEntryName(name []byte) error {
+	switch string(name) {
+	case "", ".", "..", RepositoryDir:
+		return fmt.Errorf("Invalid tree entry name %q", name)
+	}
+	if bytes.ContainsAny(name, "/\x00") {
+		return fmt.Errorf("Invalid tree entry name %q", name)
+	}
+	return nil
 }
 
-// Serialize tree
+// Serialize tree. Children are written sorted by name, so equal trees have equal hashes
+// regardless of order they were collected in. Default modes are not written, so trees
+// without executables and symlinks keep hashes they had before modes were tracked.
 func (t *Tree) Serialize() (data []byte, err error) {
-	var b bytes.Buffer
-	encoder := gob.NewEncoder(&b)
-	err = encoder.Encode(t)
-	data = b.Bytes()
+	children := make([]Child, len(t.Children))
+	copy(children, t.Children)
+	sort.SliceStable(children, func(i, j int) bool {
+		return bytes.Compare(children[i].Name, children[j].Name) < 0
+	})
+	for _, c := range children {
+		if err := CheckEntryName(c.Name); err != nil {
+			return nil, err
+		}
+		var entry []byte
+		entry = appendUintField(entry, tagChildType, uint64(c.Type))
+		entry = appendField(entry, tagChildName, c.Name)
+		entry = appendField(entry, tagChildHash, c.Hash)
+		if mode := c.FileMode(); mode != DefaultMode(c.Type) {
+			entry = appendUintField(entry, tagChildMode, uint64(mode))
+		}
+		data = binary.AppendUvarint(data, uint64(len(entry)))
+		data = append(data, entry...)
+	}
 	return
 }
 
 // Deserialize data into tree
 func DeserializeTree(data []byte) (*Tree, error) {
+	tree := Tree{
+		Children: []Child{},
+	}
+	for len(data) > 0 {
+		length, n := binary.Uvarint(data)
+		if n <= 0 || length > uint64(len(data)-n) {
+			return nil, errors.New("Malformed tree entry")
+		}
+		var c Child
+		err := readFields(data[n:n+int(length)], func(tag uint64, value []byte) error {
+			switch tag {
+			case tagChildType:
+				t, err := readUint(value)
+				c.Type = uint(t)
+				return err
+			case tagChildName:
+				c.Name = clone(value)
+			case tagChildHash:
+				c.Hash = clone(value)
+			case tagChildMode:
+				m, err := readUint(value)
+				c.Mode = uint32(m)
+				return err
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := CheckEntryName(c.Name); err != nil {
+			return nil, err
+		}
+		c.Mode = c.FileMode()
+		tree.Children = append(tree.Children, c)
+		data = data[n+int(length):]
+	}
+	return &tree, nil
+}
+
+// Deserialize tree written with gob before versioned encoding
+func deserializeGobTree(data []byte) (*Tree, error) {
 	var tree Tree
 	decoder := gob.NewDecoder(bytes.NewReader(data))
 	err := decoder.Decode(&tree)
-	return &tree, err
+	if err != nil {
+		return nil, err
+	}
+	for i := range tree.Children {
+		if err := CheckEntryName(tree.Children[i].Name); err != nil {
+			return nil, err
+		}
+		tree.Children[i].Mode = tree.Children[i].FileMode()
+	}
+	return &tree, nil
 }
 
 // Create object for tree
@@ -41,7 +153,7 @@ func (t *Tree) CreateObject() (*Object, error) {
 		return nil, err
 	}
 	return &Object{
-		TypeTree,
-		data,
+		Type: TypeTree,
+		Data: data,
 	}, nil
 }
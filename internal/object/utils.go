@@ -3,14 +3,61 @@ package object
 import (
 	"bytes"
 	"compress/zlib"
+	"crypto/sha1"
 	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"hash"
 	"io"
 )
 
-// Calculate sha256 hash of data
+// Hash algorithms of objects
+const (
+	HashSHA256     = "sha256"
+	HashSHA1       = "sha1"
+	HashSHA512_256 = "sha512/256"
+)
+
+var hashFunctions = map[string]func() hash.Hash{
+	HashSHA256:     sha256.New,
+	HashSHA1:       sha1.New,
+	HashSHA512_256: sha512.New512_256,
+}
+
+// Algorithm used by CalculateHash, set from metadata of opened repository
+var hashAlgorithm = HashSHA256
+var newHash = sha256.New
+
+// Names of supported hash algorithms
+func HashAlgorithms() []string {
+	return []string{HashSHA256, HashSHA1, HashSHA512_256}
+}
+
+// Select hash algorithm of objects
+func SetHashAlgorithm(name string) error {
+	f, ok := hashFunctions[name]
+	if !ok {
+		return fmt.Errorf("hash algorithm %s is not supported", name)
+	}
+	hashAlgorithm, newHash = name, f
+	return nil
+}
+
+// Name of selected hash algorithm
+func HashAlgorithm() string {
+	return hashAlgorithm
+}
+
+// Length of object hash in bytes
+func HashSize() int {
+	return newHash().Size()
+}
+
+// Calculate hash of data with selected algorithm
 func CalculateHash(data []byte) []byte {
-	hash := sha256.Sum256(data)
-	return hash[:]
+	h := newHash()
+	h.Write(data)
+	return h.Sum(nil)
 }
 
 // Zip data with zlib
@@ -0,0 +1,359 @@
+package sequencer
+
+import (
+	"bytes"
+	"fmt"
+	"mymodule/internal/history"
+	"mymodule/internal/merge"
+	"mymodule/internal/object"
+	"mymodule/internal/storage"
+	"os"
+	"strings"
+)
+
+// Operations replaying commits
+const (
+	CHERRY_PICK = "cherry-pick"
+	REVERT      = "revert"
+	REBASE      = "rebase"
+)
+
+// Number of hex digits of abbreviated hash in conflict labels
+const SHORT_HASH = 7
+
+// Replayed commit and commit created for it, nil if its changes were already there
+type Step struct {
+	Source []byte
+	Commit []byte
+}
+
+// Replaying stopped because changes of commit conflict with current branch
+type ConflictError struct {
+	Operation string
+	Commit    []byte
+	Paths     []string
+}
+
+func (e *ConflictError) Error() string {
+	verb := "apply"
+	if e.Operation == REVERT {
+		verb = "revert"
+	}
+	return fmt.Sprintf("could not %s %x, conflicts in: %s\nresolve them and run \"%s --continue\", or cancel with \"%s --abort\"",
+		verb, e.Commit, strings.Join(e.Paths, ", "), e.Operation, e.Operation)
+}
+
+// Apply changes of commits, each relative to its first parent, onto current branch, making
+// one commit per commit with its author and description. Working tree must be clean.
+// On conflict files get conflict markers and *ConflictError is returned; state is kept in
+// database for Continue or Abort. Steps done before are returned in any case.
+func CherryPick(s *storage.Storage, commits [][]byte) ([]*Step, error) {
+	return start(s, CHERRY_PICK, commits, "")
+}
+
+// Apply inverse of changes of commits, each relative to its first parent, onto current
+// branch, making one commit by author per commit. Conflicts are handled as in CherryPick.
+func Revert(s *storage.Storage, commits [][]byte, author string) ([]*Step, error) {
+	return start(s, REVERT, commits, author)
+}
+
+// Replay commits of current branch not reachable from upstream, except merges, on top of
+// upstream, oldest first. Branch is moved to upstream before that, so its new commits have
+// new parents; if branch is behind upstream it is just moved. Conflicts are handled as in
+// CherryPick, commit stopped on can also be skipped.
+func Rebase(s *storage.Storage, upstream []byte) ([]*Step, error) {
+	fs, err := begin(s)
+	if err != nil {
+		return nil, err
+	}
+	head := s.Refs[s.Branch]
+	upToDate, err := s.IsAncestor(upstream, head)
+	if err != nil || upToDate {
+		return nil, err
+	}
+	entries, err := history.Walk(s, [][]byte{head}, &history.Filter{Exclude: [][]byte{upstream}})
+	if err != nil {
+		return nil, err
+	}
+	seq := &storage.Sequencer{
+		Operation: REBASE,
+		Branch:    s.Branch,
+		Head:      head,
+		Todo:      make([][]byte, 0, len(entries)),
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		if len(entries[i].Commit.Merges) == 0 {
+			seq.Todo = append(seq.Todo, entries[i].Hash)
+		}
+	}
+	err = s.SetSequencer(seq)
+	if err != nil {
+		return nil, err
+	}
+	upstreamData, err := s.GetCommit(upstream)
+	if err != nil {
+		return nil, err
+	}
+	err = s.UpdateWorkTree(fs, upstreamData.Commit.Tree)
+	if err != nil {
+		return nil, err
+	}
+	err = s.UpdateBranches(map[string][]byte{s.Branch: upstream}, fmt.Sprintf("rebase (start): checkout %x", upstream))
+	if err != nil {
+		return nil, err
+	}
+	return run(s, seq)
+}
+
+// Start operation replaying commits
+func start(s *storage.Storage, operation string, commits [][]byte, committer string) ([]*Step, error) {
+	_, err := begin(s)
+	if err != nil {
+		return nil, err
+	}
+	seq := &storage.Sequencer{
+		Operation: operation,
+		Branch:    s.Branch,
+		Head:      s.Refs[s.Branch],
+		Todo:      commits,
+		Committer: committer,
+	}
+	err = s.SetSequencer(seq)
+	if err != nil {
+		return nil, err
+	}
+	return run(s, seq)
+}
+
+// Check that no operation is in progress and working tree is clean, returns scanned tree
+func begin(s *storage.Storage) (*storage.FileSystem, error) {
+	seq, err := s.GetSequencer()
+	if err != nil {
+		return nil, err
+	}
+	if seq != nil {
+		return nil, seq.InProgress()
+	}
+	return s.CleanWorkTree()
+}
+
+// Commit resolved conflicts of operation in progress and replay commits left
+func Continue(s *storage.Storage, operation string) ([]*Step, error) {
+	seq, err := inProgress(s, operation)
+	if err != nil {
+		return nil, err
+	}
+	steps := make([]*Step, 0)
+	if seq.Current != nil {
+		for _, path := range seq.Conflicts {
+			if hasMarkers(s.Path + "/" + path) {
+				return nil, fmt.Errorf("file %s still has conflict markers, resolve it first", path)
+			}
+		}
+		fs, err := storage.InitFileSystem(s.Path, s.Workers)
+		if err != nil {
+			return nil, err
+		}
+		step, err := commit(s, seq, fs.TreeMap, fs.ROOT_HASH)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, step)
+		seq.Current, seq.Author, seq.Description, seq.Conflicts = nil, "", "", nil
+	}
+	more, err := run(s, seq)
+	return append(steps, more...), err
+}
+
+// Drop commit operation stopped on, with changes made to files since then, and replay
+// commits left
+func Skip(s *storage.Storage, operation string) ([]*Step, error) {
+	seq, err := inProgress(s, operation)
+	if err != nil {
+		return nil, err
+	}
+	if seq.Current == nil {
+		return nil, fmt.Errorf("%s is not stopped on a commit, nothing to skip", operation)
+	}
+	headData, err := s.GetCommit(s.Refs[s.Branch])
+	if err != nil {
+		return nil, err
+	}
+	fs, err := storage.InitFileSystem(s.Path, s.Workers)
+	if err != nil {
+		return nil, err
+	}
+	err = s.UpdateWorkTree(fs, headData.Commit.Tree)
+	if err != nil {
+		return nil, err
+	}
+	seq.Current, seq.Author, seq.Description, seq.Conflicts = nil, "", "", nil
+	return run(s, seq)
+}
+
+// Cancel operation in progress: branch and working tree are returned to the state they had
+// before it started. Changes made to files since then are lost.
+func Abort(s *storage.Storage, operation string) error {
+	seq, err := inProgress(s, operation)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(s.Refs[seq.Branch], seq.Head) {
+		err = s.UpdateBranches(map[string][]byte{seq.Branch: seq.Head}, seq.Operation+": abort")
+		if err != nil {
+			return err
+		}
+	}
+	headData, err := s.GetCommit(seq.Head)
+	if err != nil {
+		return err
+	}
+	fs, err := storage.InitFileSystem(s.Path, s.Workers)
+	if err != nil {
+		return err
+	}
+	err = s.UpdateWorkTree(fs, headData.Commit.Tree)
+	if err != nil {
+		return err
+	}
+	return s.ClearSequencer()
+}
+
+// State of operation in progress, error if there is none or another one is
+func inProgress(s *storage.Storage, operation string) (*storage.Sequencer, error) {
+	seq, err := s.GetSequencer()
+	if err != nil {
+		return nil, err
+	}
+	if seq == nil {
+		return nil, fmt.Errorf("no %s is in progress", operation)
+	}
+	if seq.Operation != operation {
+		return nil, seq.InProgress()
+	}
+	if seq.Branch != s.Branch {
+		return nil, fmt.Errorf("%s was started on branch %s, but current branch is %s", seq.Operation, seq.Branch, s.Branch)
+	}
+	return seq, nil
+}
+
+// Replay commits left in seq. State is saved after every commit, so operation interrupted
+// by an error can be continued too.
+func run(s *storage.Storage, seq *storage.Sequencer) ([]*Step, error) {
+	steps := make([]*Step, 0)
+	for len(seq.Todo) > 0 {
+		source := seq.Todo[0]
+		sourceData, err := s.GetCommit(source)
+		if err != nil {
+			return steps, err
+		}
+		headData, err := s.GetCommit(s.Refs[s.Branch])
+		if err != nil {
+			return steps, err
+		}
+		base, theirs, label, author, description := plan(seq, sourceData)
+		result, err := merge.Apply(s, headData.Commit.Tree, base, theirs, storage.HEAD, label)
+		if err != nil {
+			return steps, err
+		}
+		fs, err := storage.InitFileSystem(s.Path, s.Workers)
+		if err != nil {
+			return steps, err
+		}
+		err = writeResult(s, fs, result)
+		if err != nil {
+			return steps, err
+		}
+
+		seq.Todo = seq.Todo[1:]
+		seq.Current = source
+		seq.Author, seq.Description = author, description
+		if len(result.Conflicts) > 0 {
+			seq.Conflicts = result.Conflicts
+			err = s.SetSequencer(seq)
+			if err != nil {
+				return steps, err
+			}
+			return steps, &ConflictError{seq.Operation, source, result.Conflicts}
+		}
+		step, err := commit(s, seq, result.Objects, result.Tree)
+		if err != nil {
+			return steps, err
+		}
+		steps = append(steps, step)
+		seq.Current, seq.Author, seq.Description = nil, "", ""
+		if len(seq.Todo) > 0 {
+			err = s.SetSequencer(seq)
+			if err != nil {
+				return steps, err
+			}
+		}
+	}
+	return steps, s.ClearSequencer()
+}
+
+// Store new objects of merge result and make working tree scanned into fs match its tree
+func writeResult(s *storage.Storage, fs *storage.FileSystem, result *merge.Result) error {
+	_, _, err := s.StoreObjects(result.Objects)
+	if err != nil {
+		return err
+	}
+	return s.UpdateWorkTree(fs, result.Tree)
+}
+
+// Changes made for commit by operation, from commit base to commit theirs, label of theirs
+// in conflicts, author and description of commit made for them. Merge commit is replayed
+// relative to its first parent.
+func plan(seq *storage.Sequencer, source *storage.CommitData) (base, theirs []byte, label, author, description string) {
+	var parent []byte
+	if len(source.Commit.Origin) > 0 {
+		parent = source.Commit.Origin
+	}
+	short := fmt.Sprintf("%x", source.Hash)[:SHORT_HASH]
+	text := string(source.Commit.Description)
+	switch seq.Operation {
+	case REVERT:
+		description = fmt.Sprintf("Revert \"%s\"\n\nThis reverts commit %x.", summary(text), source.Hash)
+		return source.Hash, parent, "parent of " + short + " " + summary(text), seq.Committer, description
+	case REBASE:
+		return parent, source.Hash, short + " " + summary(text), string(source.Commit.Author), text
+	}
+	description = strings.TrimRight(text, "\n") + fmt.Sprintf("\n\n(cherry picked from commit %x)", source.Hash)
+	return parent, source.Hash, short + " " + summary(text), string(source.Commit.Author), description
+}
+
+// Commit tree made for current commit of seq. Nothing is committed if tree has no changes.
+func commit(s *storage.Storage, seq *storage.Sequencer, objects map[string]*object.Object, tree []byte) (*Step, error) {
+	headData, err := s.GetCommit(s.Refs[s.Branch])
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(headData.Commit.Tree, tree) {
+		return &Step{Source: seq.Current}, nil
+	}
+	result, err := s.CommitTree(objects, tree, seq.Author, seq.Description, seq.Committer, seq.Operation)
+	if err != nil {
+		return nil, err
+	}
+	return &Step{Source: seq.Current, Commit: result.Hash}, nil
+}
+
+// Check if file has a line starting conflict
+func hasMarkers(path string) bool {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, merge.MARKER_OURS+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+// First line of description
+func summary(description string) string {
+	line, _, _ := strings.Cut(description, "\n")
+	return line
+}
@@ -0,0 +1,29 @@
+package sequencer
+
+import (
+	"mymodule/internal/merge"
+	"mymodule/internal/storage"
+)
+
+// Apply changes saved in stash commit, relative to commit it was made on, to clean working
+// tree. Nothing is committed. Files changed on both sides are merged by lines; paths of
+// conflicting ones, left with conflict markers, are returned.
+func ApplyStash(s *storage.Storage, stash []byte) ([]string, error) {
+	fs, err := begin(s)
+	if err != nil {
+		return nil, err
+	}
+	headData, err := s.GetCommit(s.Refs[s.Branch])
+	if err != nil {
+		return nil, err
+	}
+	stashData, err := s.GetCommit(stash)
+	if err != nil {
+		return nil, err
+	}
+	result, err := merge.Apply(s, headData.Commit.Tree, stashData.Commit.Origin, stash, storage.HEAD, storage.STASH)
+	if err != nil {
+		return nil, err
+	}
+	return result.Conflicts, writeResult(s, fs, result)
+}
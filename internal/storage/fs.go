@@ -5,35 +5,66 @@ import (
 	"fmt"
 	"mymodule/internal/object"
 	"os"
+	"runtime"
+	"strconv"
+	"sync"
 )
 
+// Environment variable overriding number of files hashed concurrently
+const WORKERS_ENV = "VCS_WORKERS"
+
 // Current file system state
 type FileSystem struct {
 	path      string                    //Path to vcs
 	ROOT_HASH []byte                    //Hash of root object
 	TreeMap   map[string]*object.Object //Map of object for current file system state
+	workers   int                       //Number of files read and hashed concurrently
+	mu        sync.Mutex                //Guards TreeMap
+}
+
+// Entry of scanned directory
+type fsEntry struct {
+	path     string
+	name     string
+	isDir    bool
+	mode     uint32         //Mode of tree entry, one of object.Mode constants
+	children []*fsEntry     //Entries of directory, in ReadDir order
+	obj      *object.Object //Blob of file, filled by workers
+	hash     []byte         //Hash of blob
 }
 
 // Get stored in map object for key
 func (fs *FileSystem) GetObject(key []byte) (*object.Object, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
 	return fs.TreeMap[fmt.Sprintf("%x", key)], nil
 }
 
 func (fs *FileSystem) SetObject(key []byte, data *object.Object) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
 	fs.TreeMap[fmt.Sprintf("%x", key)] = data
 }
 
-func InitFileSystem(path string) (*FileSystem, error) {
-	fs := &FileSystem{
-		path,
-		[]byte{},
-		make(map[string]*object.Object),
+// Default number of workers: VCS_WORKERS if set, number of CPUs otherwise
+func DefaultWorkers() int {
+	if n, err := strconv.Atoi(os.Getenv(WORKERS_ENV)); err == nil && n > 0 {
+		return n
 	}
-	rootTree, err := fs.CreateTree(path)
-	if err != nil {
-		return nil, err
+	return runtime.NumCPU()
+}
+
+func InitFileSystem(path string, workers int) (*FileSystem, error) {
+	if workers < 1 {
+		workers = 1
 	}
-	hash, err := rootTree.GetHash()
+	fs := &FileSystem{
+		path:      path,
+		ROOT_HASH: []byte{},
+		TreeMap:   make(map[string]*object.Object),
+		workers:   workers,
+	}
+	_, hash, err := fs.CreateTree(path)
 	if err != nil {
 		return nil, err
 	}
@@ -42,55 +73,166 @@ func InitFileSystem(path string) (*FileSystem, error) {
 	return fs, nil
 }
 
-// Creating tree for current file system state
-func (fs *FileSystem) CreateTree(path string) (*object.Object, error) {
-	stat, err := os.Stat(path)
+// Creating tree for current file system state. Directory structure is scanned first,
+// then files are read and hashed by a pool of workers, and finally trees are built
+// in directory order, so resulting hashes do not depend on scheduling.
+func (fs *FileSystem) CreateTree(path string) (*object.Object, []byte, error) {
+	files := make([]*fsEntry, 0)
+	root, err := scan(path, "", &files)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = fs.hashFiles(files)
 	if err != nil {
+		return nil, nil, err
+	}
+	return fs.buildTree(root)
+}
 
+// Scan directory structure, collecting files to hash. Symlinks are not followed, except
+// for the root directory itself.
+func scan(path string, name string, files *[]*fsEntry) (*fsEntry, error) {
+	var stat os.FileInfo
+	var err error
+	if name == "" {
+		stat, err = os.Stat(path)
+	} else {
+		stat, err = os.Lstat(path)
+	}
+	if err != nil {
 		return nil, err
 	}
-
-	//if path is file create Blob object
-	if !stat.IsDir() {
-		data, err := os.ReadFile(path)
-		if err != nil {
-			return nil, err
-		}
-		blob := object.Blob{
-			Data: data,
-		}
-		obj := blob.CreateObject()
-
-		hash, err := obj.GetHash()
-		if err != nil {
-			return nil, err
-		}
-		fs.SetObject(hash, obj)
-		return obj, nil
+	entry := &fsEntry{
+		path:  path,
+		name:  name,
+		isDir: stat.IsDir(),
+		mode:  fileMode(stat),
+	}
+	if entry.mode == 0 {
+		// devices, pipes and sockets are not versioned
+		return nil, nil
+	}
+	if !entry.isDir {
+		*files = append(*files, entry)
+		return entry, nil
 	}
 
-	//else collect children objects
 	entries, err := os.ReadDir(path)
 	if err != nil {
 		return nil, err
 	}
-	children := make([]object.Child, 0)
 	for _, e := range entries {
-		if e.Name() == ".vcs" {
+		if e.Name() == VCS_DIR {
 			continue
 		}
-		obj, err := fs.CreateTree(path + "/" + e.Name())
+		child, err := scan(path+"/"+e.Name(), e.Name(), files)
 		if err != nil {
 			return nil, err
 		}
-		hash, err := obj.GetHash()
+		if child != nil {
+			entry.children = append(entry.children, child)
+		}
+	}
+	return entry, nil
+}
+
+// Mode of tree entry for file, 0 for files that can't be versioned
+func fileMode(stat os.FileInfo) uint32 {
+	switch {
+	case stat.Mode()&os.ModeSymlink != 0:
+		return object.ModeSymlink
+	case stat.IsDir():
+		return object.ModeDirectory
+	case !stat.Mode().IsRegular():
+		return 0
+	case stat.Mode()&0o111 != 0:
+		return object.ModeExecutable
+	}
+	return object.ModeRegular
+}
+
+// Read files and create blob objects for them concurrently
+func (fs *FileSystem) hashFiles(files []*fsEntry) error {
+	jobs := make(chan *fsEntry)
+	errs := make(chan error, fs.workers)
+	var wg sync.WaitGroup
+	for i := 0; i < fs.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var err error
+			for entry := range jobs {
+				// keep draining jobs after error, so sender never blocks
+				if err != nil {
+					continue
+				}
+				err = fs.hashFile(entry)
+			}
+			errs <- err
+		}()
+	}
+	for _, entry := range files {
+		jobs <- entry
+	}
+	close(jobs)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
 		if err != nil {
-			return nil, err
+			return err
+		}
+	}
+	return nil
+}
+
+// Create Blob object for file, blob of symlink holds its target
+func (fs *FileSystem) hashFile(entry *fsEntry) error {
+	var data []byte
+	var err error
+	if entry.mode == object.ModeSymlink {
+		var target string
+		target, err = os.Readlink(entry.path)
+		data = []byte(target)
+	} else {
+		data, err = os.ReadFile(entry.path)
+	}
+	if err != nil {
+		return err
+	}
+	blob := object.Blob{
+		Data: data,
+	}
+	obj := blob.CreateObject()
+
+	hash, err := obj.GetHash()
+	if err != nil {
+		return err
+	}
+	fs.SetObject(hash, obj)
+	entry.obj = obj
+	entry.hash = hash
+	return nil
+}
+
+// Create tree objects from scanned entries with hashed files
+func (fs *FileSystem) buildTree(entry *fsEntry) (*object.Object, []byte, error) {
+	//if entry is file, its Blob object is ready
+	if !entry.isDir {
+		return entry.obj, entry.hash, nil
+	}
+
+	//else collect children objects
+	children := make([]object.Child, 0, len(entry.children))
+	for _, e := range entry.children {
+		obj, hash, err := fs.buildTree(e)
+		if err != nil {
+			return nil, nil, err
 		}
 		children = append(children, object.Child{
 			Type: obj.Type,
-			Name: []byte(e.Name()),
+			Name: []byte(e.name),
 			Hash: hash,
+			Mode: e.mode,
 		})
 	}
 	//and create tree object with that children
@@ -99,12 +241,12 @@ func (fs *FileSystem) CreateTree(path string) (*object.Object, error) {
 	}
 	obj, err := tree.CreateObject()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	hash, err := obj.GetHash()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	fs.SetObject(hash, obj)
-	return obj, nil
+	return obj, hash, nil
 }
@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Name of repository lock file inside .vcs directory
+const LOCK_FILE = "vcs.lock"
+
+// How long to wait for another process to release the repository
+const LOCK_TIMEOUT = 3 * time.Second
+
+// Repository is used by another process
+type BusyError struct {
+	PID int //Process holding exclusive lock, 0 if unknown
+}
+
+func (e *BusyError) Error() string {
+	if e.PID != 0 {
+		return fmt.Sprintf("repository is busy: locked by process %d", e.PID)
+	}
+	return "repository is busy: used by another process"
+}
+
+// Lock of repository. Writers hold it exclusively for the duration of one operation,
+// readers share it.
+type repoLock struct {
+	file      *os.File
+	exclusive bool
+}
+
+// Acquire repository lock, waiting at most LOCK_TIMEOUT
+func acquireLock(dir string, exclusive bool) (*repoLock, error) {
+	f, err := os.OpenFile(dir+"/"+LOCK_FILE, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(LOCK_TIMEOUT)
+	for {
+		ok, err := tryLock(f, exclusive)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		if ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			pid := lockHolder(f)
+			f.Close()
+			return nil, &BusyError{pid}
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if exclusive {
+		// record holder, so other processes can report who blocks them
+		err = f.Truncate(0)
+		if err == nil {
+			_, err = f.WriteAt([]byte(strconv.Itoa(os.Getpid())), 0)
+		}
+		if err != nil {
+			unlock(f)
+			f.Close()
+			return nil, err
+		}
+	}
+	return &repoLock{f, exclusive}, nil
+}
+
+// Release repository lock
+func (l *repoLock) release() error {
+	if l.exclusive {
+		l.file.Truncate(0)
+	}
+	err := unlock(l.file)
+	closeErr := l.file.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
+
+// PID written by exclusive holder of lock
+func lockHolder(f *os.File) int {
+	data, err := os.ReadFile(f.Name())
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0
+	}
+	return pid
+}
+
+// Badger reports its own directory lock as plain error; it means the same
+// as our lock being held.
+func isBadgerLockError(err error) bool {
+	return strings.Contains(err.Error(), "Cannot acquire directory lock")
+}
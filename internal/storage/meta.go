@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"mymodule/internal/object"
+	"time"
+
+	"github.com/dgraph-io/badger"
+)
+
+const META_KEY = "META"
+
+// Version of repository layout written by this program. Repositories with newer version
+// are not opened, older ones are upgraded by steps in upgrades on first writable open.
+const FORMAT_VERSION = 1
+
+// Repository metadata, written on init
+type Metadata struct {
+	FormatVersion  int    //Version of repository layout
+	HashAlgorithm  string //Algorithm of object hashes
+	ObjectEncoding int    //Version of object encoding objects are written in, 0 if some may be gob encoded
+	Created        int64  //Time of repository creation
+}
+
+// Upgrade steps, upgrades[v] converts repository of format version v into v+1
+var upgrades = map[int]func(s *Storage) error{
+	0: upgradeMetadata,
+}
+
+func SerializeMetadata(meta *Metadata) ([]byte, error) {
+	var b bytes.Buffer
+	encoder := gob.NewEncoder(&b)
+	err := encoder.Encode(meta)
+	return b.Bytes(), err
+}
+
+func DeserializeMetadata(data []byte) (*Metadata, error) {
+	var meta Metadata
+	decoder := gob.NewDecoder(bytes.NewReader(data))
+	err := decoder.Decode(&meta)
+	return &meta, err
+}
+
+// Metadata of repository created now with objects hashed by hashAlgorithm
+func newMetadata(hashAlgorithm string) *Metadata {
+	return &Metadata{
+		FormatVersion:  FORMAT_VERSION,
+		HashAlgorithm:  hashAlgorithm,
+		ObjectEncoding: object.EncodingVersion,
+		Created:        time.Now().Unix(),
+	}
+}
+
+// Load metadata and check that this program supports repository. Older repository is
+// upgraded when opened for writing; read-only storage reads it as is.
+func (s *Storage) loadMetadata() error {
+	data, err := s.GetData([]byte(META_KEY))
+	if err == badger.ErrKeyNotFound {
+		// repositories created before metadata existed, their objects are gob encoded
+		// until migrate rewrites them
+		s.Meta = &Metadata{
+			FormatVersion:  0,
+			HashAlgorithm:  object.HashSHA256,
+			ObjectEncoding: 0,
+		}
+	} else if err != nil {
+		return err
+	} else {
+		s.Meta, err = DeserializeMetadata(data)
+		if err != nil {
+			return err
+		}
+	}
+
+	if s.Meta.FormatVersion > FORMAT_VERSION {
+		return fmt.Errorf("repository format version %d is not supported, this program supports up to %d", s.Meta.FormatVersion, FORMAT_VERSION)
+	}
+	err = object.SetHashAlgorithm(s.Meta.HashAlgorithm)
+	if err != nil {
+		return fmt.Errorf("repository %w", err)
+	}
+	if s.Meta.ObjectEncoding > object.EncodingVersion {
+		return fmt.Errorf("repository object encoding version %d is not supported, this program supports up to %d", s.Meta.ObjectEncoding, object.EncodingVersion)
+	}
+	if s.ReadOnly || s.Meta.FormatVersion == FORMAT_VERSION {
+		return nil
+	}
+	return s.upgrade()
+}
+
+// Run upgrade steps from version of repository up to current one
+func (s *Storage) upgrade() error {
+	from := s.Meta.FormatVersion
+	for s.Meta.FormatVersion < FORMAT_VERSION {
+		step, ok := upgrades[s.Meta.FormatVersion]
+		if !ok {
+			return fmt.Errorf("repository format version %d can't be upgraded", s.Meta.FormatVersion)
+		}
+		err := step(s)
+		if err != nil {
+			return fmt.Errorf("upgrade of repository format version %d: %w", s.Meta.FormatVersion, err)
+		}
+	}
+	fmt.Printf("Repository upgraded from format version %d to %d\n", from, FORMAT_VERSION)
+	return nil
+}
+
+// Version 0 had no metadata. Creation time is taken from the oldest reflog entry. Objects
+// stay gob encoded until migrate, so object encoding is recorded as 0.
+func upgradeMetadata(s *Storage) error {
+	meta := newMetadata(object.HashSHA256)
+	meta.FormatVersion = 1
+	meta.ObjectEncoding = 0
+	entries, err := s.GetReflog(HEAD)
+	if err != nil {
+		return err
+	}
+	if len(entries) > 0 {
+		meta.Created = entries[len(entries)-1].Time
+	}
+	return s.setMetadata(meta)
+}
+
+func (s *Storage) setMetadata(meta *Metadata) error {
+	data, err := SerializeMetadata(meta)
+	if err != nil {
+		return err
+	}
+	err = s.SetData([]byte(META_KEY), data)
+	if err != nil {
+		return err
+	}
+	s.Meta = meta
+	return nil
+}
@@ -0,0 +1,262 @@
+package storage
+
+import (
+	"encoding/hex"
+	"mymodule/internal/object"
+
+	"github.com/dgraph-io/badger"
+)
+
+// Prefix of keys mapping hash of migrated object to its new hash
+const MIGRATE_PREFIX = "MIGRATE/"
+
+// Rewriting of gob encoded objects into versioned encoding
+type migration struct {
+	s       *Storage
+	mapping map[string][]byte         //Hex hash of visited object to its new hash
+	objects map[string]*object.Object //New objects by hex hash
+}
+
+// Rewrite all objects reachable from refs, stash and reflogs into versioned encoding.
+// Old objects stay in database, so their hashes remain readable. Returns mapping of hex old
+// hash to new hash for every object whose hash changed. Metadata then records current
+// object encoding.
+func (s *Storage) Migrate() (map[string][]byte, error) {
+	seq, err := s.GetSequencer()
+	if err != nil {
+		return nil, err
+	}
+	if seq != nil {
+		return nil, seq.InProgress()
+	}
+	m := &migration{
+		s:       s,
+		mapping: make(map[string][]byte),
+		objects: make(map[string]*object.Object),
+	}
+
+	refs := s.copyRefs()
+	for branch, hash := range refs {
+		newHash, err := m.convert(hash)
+		if err != nil {
+			return nil, err
+		}
+		refs[branch] = newHash
+	}
+	stash, err := s.GetStash()
+	if err != nil {
+		return nil, err
+	}
+	for i, hash := range stash {
+		stash[i], err = m.convert(hash)
+		if err != nil {
+			return nil, err
+		}
+	}
+	logs, err := s.allReflogs()
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range logs {
+		for _, hash := range [][]byte{entry.Old, entry.New} {
+			if len(hash) == 0 {
+				continue
+			}
+			// log may point to objects that never were stored
+			_, err := m.convert(hash)
+			if err != nil && err != badger.ErrKeyNotFound {
+				return nil, err
+			}
+		}
+	}
+
+	changed := make(map[string][]byte)
+	for key, hash := range m.mapping {
+		if key != hex.EncodeToString(hash) {
+			changed[key] = hash
+		}
+	}
+	if len(changed) == 0 {
+		return changed, s.setObjectEncoding()
+	}
+
+	_, _, err = s.StoreObjects(m.objects)
+	if err != nil {
+		return nil, err
+	}
+	wb := s.DB.NewWriteBatch()
+	defer wb.Cancel()
+	for key, hash := range changed {
+		old, _ := hex.DecodeString(key)
+		err = wb.Set(append([]byte(MIGRATE_PREFIX), old...), hash)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for key, entry := range logs {
+		entry.Old = m.mapped(entry.Old)
+		entry.New = m.mapped(entry.New)
+		data, err := SerializeReflogEntry(entry)
+		if err != nil {
+			return nil, err
+		}
+		err = wb.Set([]byte(key), data)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = wb.Flush()
+	if err != nil {
+		return nil, err
+	}
+
+	// refs, stash and metadata are switched last in one transaction, until then
+	// repository uses old objects
+	refsData, err := SerializeRefs(refs)
+	if err != nil {
+		return nil, err
+	}
+	meta := *s.Meta
+	meta.ObjectEncoding = object.EncodingVersion
+	metaData, err := SerializeMetadata(&meta)
+	if err != nil {
+		return nil, err
+	}
+	err = s.DB.Update(func(txn *badger.Txn) error {
+		if err := txn.Set([]byte(REFS_KEY), refsData); err != nil {
+			return err
+		}
+		if err := writeStash(txn, stash); err != nil {
+			return err
+		}
+		return txn.Set([]byte(META_KEY), metaData)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.Refs = refs
+	s.Meta = &meta
+	return changed, nil
+}
+
+// Record that reachable objects are in current encoding
+func (s *Storage) setObjectEncoding() error {
+	if s.Meta.ObjectEncoding == object.EncodingVersion {
+		return nil
+	}
+	meta := *s.Meta
+	meta.ObjectEncoding = object.EncodingVersion
+	return s.setMetadata(&meta)
+}
+
+// Convert object and everything it references, returns new hash
+func (m *migration) convert(hash []byte) ([]byte, error) {
+	key := hex.EncodeToString(hash)
+	if newHash, ok := m.mapping[key]; ok {
+		return newHash, nil
+	}
+	obj, err := m.s.GetObject(hash)
+	if err != nil {
+		return nil, err
+	}
+	// new trees and blobs reference only new objects, new commits may follow legacy ones
+	if !obj.IsLegacy() && obj.Type != object.TypeCommit {
+		m.mapping[key] = hash
+		return hash, nil
+	}
+
+	var newObj *object.Object
+	switch obj.Type {
+	case object.TypeBlob:
+		newObj = &object.Object{
+			Type: obj.Type,
+			Data: obj.Data,
+		}
+	case object.TypeTree:
+		tree, err := obj.ParseTree()
+		if err != nil {
+			return nil, err
+		}
+		for i, c := range tree.Children {
+			tree.Children[i].Hash, err = m.convert(c.Hash)
+			if err != nil {
+				return nil, err
+			}
+		}
+		newObj, err = tree.CreateObject()
+		if err != nil {
+			return nil, err
+		}
+	case object.TypeCommit:
+		commit, err := obj.ParseCommit()
+		if err != nil {
+			return nil, err
+		}
+		if len(commit.Origin) != 0 {
+			commit.Origin, err = m.convert(commit.Origin)
+			if err != nil {
+				return nil, err
+			}
+		}
+		for i, merge := range commit.Merges {
+			commit.Merges[i], err = m.convert(merge)
+			if err != nil {
+				return nil, err
+			}
+		}
+		commit.Tree, err = m.convert(commit.Tree)
+		if err != nil {
+			return nil, err
+		}
+		newObj, err = commit.CreateObject()
+		if err != nil {
+			return nil, err
+		}
+	}
+	newHash, err := newObj.GetHash()
+	if err != nil {
+		return nil, err
+	}
+	m.mapping[key] = newHash
+	m.objects[hex.EncodeToString(newHash)] = newObj
+	return newHash, nil
+}
+
+// New hash of converted object, the same hash for others
+func (m *migration) mapped(hash []byte) []byte {
+	if newHash, ok := m.mapping[hex.EncodeToString(hash)]; ok {
+		return newHash
+	}
+	return hash
+}
+
+// All reflog entries of repository by key
+func (s *Storage) allReflogs() (map[string]*ReflogEntry, error) {
+	logs := make(map[string]*ReflogEntry)
+	prefix := []byte(REFLOG_PREFIX)
+	err := s.DB.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+			err := item.Value(func(val []byte) error {
+				entry, err := DeserializeReflogEntry(val)
+				if err != nil {
+					return err
+				}
+				logs[string(item.KeyCopy(nil))] = entry
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return logs, err
+}
+
+// New hash of object rewritten by migration
+func (s *Storage) migratedHash(hash []byte) ([]byte, error) {
+	return s.GetData(append([]byte(MIGRATE_PREFIX), hash...))
+}
@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"os/user"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/badger"
+)
+
+const REFLOG_PREFIX = "REFLOG/"
+
+// Name of the log that records every movement of current branch pointer
+const HEAD = "HEAD"
+
+// Record of one ref movement
+type ReflogEntry struct {
+	Old       []byte //Hash ref pointed to before
+	New       []byte //Hash ref points to after
+	Author    string
+	Time      int64
+	Operation string //Short description, e.g. "commit: message"
+}
+
+func SerializeReflogEntry(entry *ReflogEntry) ([]byte, error) {
+	var b bytes.Buffer
+	encoder := gob.NewEncoder(&b)
+	err := encoder.Encode(entry)
+	return b.Bytes(), err
+}
+
+func DeserializeReflogEntry(data []byte) (*ReflogEntry, error) {
+	var entry ReflogEntry
+	decoder := gob.NewDecoder(bytes.NewReader(data))
+	err := decoder.Decode(&entry)
+	return &entry, err
+}
+
+// Prefix of all keys of ref log. Zero byte can't appear in ref name, so logs
+// of "a" and "a/b" never overlap.
+func reflogPrefix(ref string) []byte {
+	return []byte(REFLOG_PREFIX + ref + "\x00")
+}
+
+// Append entry to log of ref inside transaction. Entries are stored under
+// increasing sequence numbers, existing ones are never rewritten.
+func appendReflog(txn *badger.Txn, ref string, entry *ReflogEntry) error {
+	prefix := reflogPrefix(ref)
+	seq := uint64(0)
+
+	opts := badger.DefaultIteratorOptions
+	opts.Reverse = true
+	opts.PrefetchValues = false
+	it := txn.NewIterator(opts)
+	it.Seek(append(append([]byte{}, prefix...), bytes.Repeat([]byte{0xff}, 9)...))
+	if it.ValidForPrefix(prefix) {
+		seq = binary.BigEndian.Uint64(it.Item().Key()[len(prefix):]) + 1
+	}
+	it.Close()
+
+	data, err := SerializeReflogEntry(entry)
+	if err != nil {
+		return err
+	}
+	key := binary.BigEndian.AppendUint64(prefix, seq)
+	return txn.Set(key, data)
+}
+
+// Get log of ref, newest entry first
+func (s *Storage) GetReflog(ref string) ([]*ReflogEntry, error) {
+	entries := make([]*ReflogEntry, 0)
+	prefix := reflogPrefix(ref)
+	err := s.DB.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Reverse = true
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Seek(append(append([]byte{}, prefix...), bytes.Repeat([]byte{0xff}, 9)...)); it.ValidForPrefix(prefix); it.Next() {
+			err := it.Item().Value(func(val []byte) error {
+				entry, err := DeserializeReflogEntry(val)
+				if err != nil {
+					return err
+				}
+				entries = append(entries, entry)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return entries, err
+}
+
+// Create log entry stamped with current time
+func newReflogEntry(old []byte, new []byte, author string, operation string) *ReflogEntry {
+	if author == "" {
+		author = currentUser()
+	}
+	return &ReflogEntry{
+		Old:       old,
+		New:       new,
+		Author:    author,
+		Time:      time.Now().Unix(),
+		Operation: operation,
+	}
+}
+
+// Name of user running the program, used as author of ref movements
+func currentUser() string {
+	u, err := user.Current()
+	if err != nil {
+		return ""
+	}
+	return u.Username
+}
+
+// First line of commit description for log messages
+func summary(description string) string {
+	line, _, _ := strings.Cut(description, "\n")
+	return line
+}
+
+// Resolve "ref@{n}": hash ref pointed to n movements ago
+func (s *Storage) resolveReflog(ref string, n int) ([]byte, error) {
+	if ref == "" {
+		ref = HEAD
+	}
+	entries, err := s.GetReflog(ref)
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 || n >= len(entries) {
+		return nil, fmt.Errorf("log for \"%s\" only has %d entries", ref, len(entries))
+	}
+	return entries[n].New, nil
+}
@@ -3,8 +3,34 @@ package storage
 import (
 	"bytes"
 	"encoding/gob"
+	"fmt"
+	"strings"
 )
 
+// Check branch name by rules of "git check-ref-format --branch": parts between "/" are
+// not empty and don't start with "." or end with ".lock", name has no "..", "@{",
+// control characters, spaces or any of ~^:?*[\ and doesn't start with "-" or end with
+// ".". HEAD is reserved for revisions. Branch names become paths of git refs on export,
+// so they must be checked before branch is created.
+func CheckBranchName(branch string) error {
+	invalid := fmt.Errorf("\"%s\" is not a valid branch name", branch)
+	if branch == HEAD || branch == "@" || strings.HasPrefix(branch, "-") || strings.HasSuffix(branch, ".") ||
+		strings.Contains(branch, "..") || strings.Contains(branch, "@{") {
+		return invalid
+	}
+	for _, r := range branch {
+		if r < 0x20 || r == 0x7f || strings.ContainsRune(" ~^:?*[\\", r) {
+			return invalid
+		}
+	}
+	for _, part := range strings.Split(branch, "/") {
+		if part == "" || strings.HasPrefix(part, ".") || strings.HasSuffix(part, ".lock") {
+			return invalid
+		}
+	}
+	return nil
+}
+
 func SerializeRefs(refs map[string][]byte) ([]byte, error) {
 	var b bytes.Buffer
 	encoder := gob.NewEncoder(&b)
@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"encoding/hex"
+	"fmt"
+	"mymodule/internal/object"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/dgraph-io/badger"
+)
+
+// Minimal length of abbreviated hash
+const MIN_ABBREV = 4
+
+var reflogRevision = regexp.MustCompile(`^(.*)@\{(\d+)\}$`)
+
+// Resolve revision into object hash. Supported forms:
+// HEAD, <branch>, <ref>@{n}, stash@{n}, full or abbreviated hex hash.
+func (s *Storage) ResolveRevision(rev string) ([]byte, error) {
+	if m := reflogRevision.FindStringSubmatch(rev); m != nil {
+		n, err := strconv.Atoi(m[2])
+		if err != nil {
+			return nil, err
+		}
+		if m[1] == STASH {
+			return s.StashEntry(n)
+		}
+		return s.resolveReflog(m[1], n)
+	}
+	if rev == HEAD {
+		return s.Refs[s.Branch], nil
+	}
+	if hash, ok := s.Refs[rev]; ok {
+		return hash, nil
+	}
+	return s.resolveHash(rev)
+}
+
+// Hashes without repeats, in order of first occurrence
+func uniqueHashes(hashes [][]byte) [][]byte {
+	seen := make(map[string]bool, len(hashes))
+	unique := make([][]byte, 0, len(hashes))
+	for _, hash := range hashes {
+		if !seen[string(hash)] {
+			seen[string(hash)] = true
+			unique = append(unique, hash)
+		}
+	}
+	return unique
+}
+
+// Resolve full or abbreviated hex hash of stored object
+func (s *Storage) resolveHash(rev string) ([]byte, error) {
+	// hex digits are matched in any case, errors show revision as typed
+	digits := strings.ToLower(rev)
+	if _, err := hex.DecodeString(digits + strings.Repeat("0", len(digits)%2)); err != nil || len(digits) < MIN_ABBREV {
+		return nil, fmt.Errorf("unknown revision \"%s\"", rev)
+	}
+	if len(digits) == 2*object.HashSize() {
+		hash, _ := hex.DecodeString(digits)
+		// object rewritten by migration resolves to its new version
+		if newHash, err := s.migratedHash(hash); err == nil {
+			return newHash, nil
+		}
+		return hash, nil
+	}
+	prefix, _ := hex.DecodeString(digits[:len(digits)-len(digits)%2])
+	matches := make([][]byte, 0)
+	size := object.HashSize()
+	err := s.DB.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			key := it.Item().KeyCopy(nil)
+			if len(key) != size || !strings.HasPrefix(hex.EncodeToString(key), digits) {
+				continue
+			}
+			// objects share key space with other records, like reflog entries, that
+			// may happen to have length of hash
+			err := it.Item().Value(func(data []byte) error {
+				_, err := object.DeserializeObject(data)
+				return err
+			})
+			if err == nil {
+				matches = append(matches, key)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// objects rewritten by migration resolve to their new versions, as full hashes do
+	for i, hash := range matches {
+		if newHash, err := s.migratedHash(hash); err == nil {
+			matches[i] = newHash
+		}
+	}
+	matches = uniqueHashes(matches)
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("unknown revision \"%s\"", rev)
+	case 1:
+		return matches[0], nil
+	default:
+		return nil, fmt.Errorf("revision \"%s\" is ambiguous", rev)
+	}
+}
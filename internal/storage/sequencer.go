@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+
+	"github.com/dgraph-io/badger"
+)
+
+// Key of state of operation replaying commits, present only while it is in progress
+const SEQUENCER_KEY = "SEQUENCER"
+
+// State of operation replaying commits onto current branch, like cherry-pick or revert.
+// It is kept in database, so operation stopped on conflict can be continued or aborted later.
+type Sequencer struct {
+	Operation   string   //Name of command that started operation
+	Branch      string   //Branch commits are replayed onto
+	Head        []byte   //Commit branch pointed to before operation, restored on abort
+	Todo        [][]byte //Commits left to replay, in order
+	Committer   string   //User running operation, author of new commits like reverts; current user if empty
+	Current     []byte   //Commit stopped on conflict, nil if none
+	Author      string   //Author of commit made for Current
+	Description string   //Description of commit made for Current
+	Conflicts   []string //Paths of conflicting files of Current
+}
+
+func SerializeSequencer(seq *Sequencer) ([]byte, error) {
+	var b bytes.Buffer
+	encoder := gob.NewEncoder(&b)
+	err := encoder.Encode(seq)
+	return b.Bytes(), err
+}
+
+func DeserializeSequencer(data []byte) (*Sequencer, error) {
+	var seq Sequencer
+	decoder := gob.NewDecoder(bytes.NewReader(data))
+	err := decoder.Decode(&seq)
+	return &seq, err
+}
+
+// Error telling that operation must be finished first
+func (seq *Sequencer) InProgress() error {
+	return fmt.Errorf("%s is in progress, finish it with \"%s --continue\" or cancel with \"%s --abort\"",
+		seq.Operation, seq.Operation, seq.Operation)
+}
+
+// State of operation in progress, nil if there is none
+func (s *Storage) GetSequencer() (*Sequencer, error) {
+	data, err := s.GetData([]byte(SEQUENCER_KEY))
+	if err == badger.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return DeserializeSequencer(data)
+}
+
+// Save state of operation in progress
+func (s *Storage) SetSequencer(seq *Sequencer) error {
+	data, err := SerializeSequencer(seq)
+	if err != nil {
+		return err
+	}
+	return s.SetData([]byte(SEQUENCER_KEY), data)
+}
+
+// Forget state of finished or aborted operation
+func (s *Storage) ClearSequencer() error {
+	return s.DB.Update(func(txn *badger.Txn) error {
+		return txn.Delete([]byte(SEQUENCER_KEY))
+	})
+}
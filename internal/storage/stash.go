@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"mymodule/internal/object"
+	"time"
+
+	"github.com/dgraph-io/badger"
+)
+
+// Key of stash: list of stash commit hashes, the newest first
+const STASH_KEY = "STASH"
+
+// Name of stash in revisions stash@{n}
+const STASH = "stash"
+
+func SerializeStash(stash [][]byte) ([]byte, error) {
+	var b bytes.Buffer
+	encoder := gob.NewEncoder(&b)
+	err := encoder.Encode(stash)
+	return b.Bytes(), err
+}
+
+func DeserializeStash(data []byte) ([][]byte, error) {
+	var stash [][]byte
+	decoder := gob.NewDecoder(bytes.NewReader(data))
+	err := decoder.Decode(&stash)
+	return stash, err
+}
+
+// Stash commits, the newest first. Stash commit holds tree of working tree and commit it
+// was made on as origin; it belongs to no branch.
+func (s *Storage) GetStash() ([][]byte, error) {
+	data, err := s.GetData([]byte(STASH_KEY))
+	if err == badger.ErrKeyNotFound {
+		return [][]byte{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return DeserializeStash(data)
+}
+
+// Hash of stash@{n}
+func (s *Storage) StashEntry(n int) ([]byte, error) {
+	stash, err := s.GetStash()
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 || n >= len(stash) {
+		return nil, fmt.Errorf("%s@{%d} does not exist", STASH, n)
+	}
+	return stash[n], nil
+}
+
+// Save working tree as stash commit on top of stash and make working tree match current
+// commit again
+func (s *Storage) PushStash(author string, description string) ([]byte, error) {
+	seq, err := s.GetSequencer()
+	if err != nil {
+		return nil, err
+	}
+	if seq != nil {
+		return nil, seq.InProgress()
+	}
+	stash, err := s.GetStash()
+	if err != nil {
+		return nil, err
+	}
+	fs, err := InitFileSystem(s.Path, s.Workers)
+	if err != nil {
+		return nil, err
+	}
+	headData, err := s.GetCommit(s.Refs[s.Branch])
+	if err != nil {
+		return nil, err
+	}
+	same, err := s.isWorkTreeAt(fs, headData.Commit.Tree)
+	if err != nil {
+		return nil, err
+	}
+	if same {
+		return nil, errors.New("no changes of working tree to save")
+	}
+
+	commit := object.Commit{
+		Origin:      headData.Hash,
+		Tree:        fs.ROOT_HASH,
+		Author:      []byte(author),
+		Description: []byte(description),
+		Time:        time.Now().Unix(),
+	}
+	commitObj, err := commit.CreateObject()
+	if err != nil {
+		return nil, err
+	}
+	commitHash, commitData, err := commitObj.GetData()
+	if err != nil {
+		return nil, err
+	}
+	stashData, err := SerializeStash(append([][]byte{commitHash}, stash...))
+	if err != nil {
+		return nil, err
+	}
+	_, _, err = s.StoreObjects(fs.TreeMap)
+	if err != nil {
+		return nil, err
+	}
+	err = s.DB.Update(func(txn *badger.Txn) error {
+		if err := txn.Set(commitHash, commitData); err != nil {
+			return err
+		}
+		return txn.Set([]byte(STASH_KEY), stashData)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return commitHash, s.UpdateWorkTree(fs, headData.Commit.Tree)
+}
+
+// Remove stash@{n} from stash, returns its hash. Commit itself stays in database.
+func (s *Storage) DropStash(n int) ([]byte, error) {
+	stash, err := s.GetStash()
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 || n >= len(stash) {
+		return nil, fmt.Errorf("%s@{%d} does not exist", STASH, n)
+	}
+	hash := stash[n]
+	return hash, s.setStash(append(stash[:n:n], stash[n+1:]...))
+}
+
+func (s *Storage) setStash(stash [][]byte) error {
+	return s.DB.Update(func(txn *badger.Txn) error {
+		return writeStash(txn, stash)
+	})
+}
+
+// Write stash in transaction, empty stash has no key
+func writeStash(txn *badger.Txn, stash [][]byte) error {
+	if len(stash) == 0 {
+		return txn.Delete([]byte(STASH_KEY))
+	}
+	data, err := SerializeStash(stash)
+	if err != nil {
+		return err
+	}
+	return txn.Set([]byte(STASH_KEY), data)
+}
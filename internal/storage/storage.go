@@ -2,14 +2,20 @@ package storage
 
 import (
 	"bytes"
+	"encoding/hex"
 	"fmt"
 	"mymodule/internal/object"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/dgraph-io/badger"
 )
 
+const VCS_DIR = object.RepositoryDir
+
 const BRANCH_KEY = "BRANCH"
 const REFS_KEY = "REFS"
 
@@ -22,6 +28,12 @@ type Storage struct {
 	Branch string
 	Refs   map[string][]byte
 	Path   string //Path to directory
+	Meta   *Metadata
+
+	Workers int //Number of files hashed concurrently
+
+	ReadOnly bool
+	lock     *repoLock
 }
 
 type CommitData struct {
@@ -29,47 +41,125 @@ type CommitData struct {
 	Commit *object.Commit
 }
 
-// Initialize database in path, if root hash not specified, build init file system and add data to database.
-func InitStorage(path string) (*Storage, error) {
-	if _, err := os.Open(path); os.IsNotExist(err) {
-		err := os.Mkdir(path, os.ModePerm)
-		if err != nil {
-			return nil, err
+// Result of commit creation
+type CommitResult struct {
+	Hash   []byte
+	Stored int //Number of new objects written to database
+	Reused int //Number of objects that were already stored
+}
+
+// Create repository in directory path with objects hashed by hashAlgorithm
+func InitStorage(path string, hashAlgorithm string) (*Storage, error) {
+	if _, err := os.Stat(path + "/" + VCS_DIR); err == nil {
+		return nil, fmt.Errorf("repository already exists in %s", path)
+	}
+	err := object.SetHashAlgorithm(hashAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+	err = os.MkdirAll(path+"/"+VCS_DIR, os.ModePerm)
+	if err != nil {
+		return nil, err
+	}
+	return openStorage(path, false, hashAlgorithm)
+}
+
+// Open repository in path. Read-only storage may be opened by several processes at once,
+// writable one holds exclusive repository lock until CloseStorage, so it should be kept
+// open only for the duration of one operation.
+func OpenStorage(path string, readOnly bool) (*Storage, error) {
+	return openStorage(path, readOnly, "")
+}
+
+// Badger wraps ErrReplayNeeded with path of log file it could not replay
+func isReplayNeeded(err error) bool {
+	return err == badger.ErrReplayNeeded || strings.Contains(err.Error(), badger.ErrReplayNeeded.Error())
+}
+
+// Find repository containing directory dir: dir itself or the nearest parent with
+// VCS_DIR in it. Returns absolute path of repository.
+func FindRepository(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for path := dir; ; {
+		stat, err := os.Stat(filepath.Join(path, VCS_DIR))
+		if err == nil && stat.IsDir() {
+			return path, nil
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return "", fmt.Errorf("not a repository (or any of the parent directories): %s, use \"init\" to create one", dir)
+		}
+		path = parent
+	}
+}
+
+// Open repository, new one is initialized with objects hashed by hashAlgorithm
+func openStorage(path string, readOnly bool, hashAlgorithm string) (*Storage, error) {
+	if _, err := os.Stat(path + "/" + VCS_DIR); err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("not a repository: %s", path)
 		}
+		return nil, err
 	}
 
+	lock, err := acquireLock(path+"/"+VCS_DIR, !readOnly)
+	if err != nil {
+		return nil, err
+	}
 	// Disable badger logs
-	opts := badger.DefaultOptions(path + "/.vcs").WithLogger(nil)
+	opts := badger.DefaultOptions(path + "/" + VCS_DIR).WithLogger(nil).WithReadOnly(readOnly)
 	db, err := badger.Open(opts)
 	if err != nil {
+		lock.release()
+		if readOnly && isReplayNeeded(err) {
+			// previous writer was interrupted, recovering needs write access
+			return openStorage(path, false, hashAlgorithm)
+		}
+		if isBadgerLockError(err) {
+			return nil, &BusyError{}
+		}
 		return nil, err
 	}
 
 	storage := &Storage{
-		db,
-		"",
-		make(map[string][]byte, 0),
-		path,
+		DB:       db,
+		Branch:   "",
+		Refs:     make(map[string][]byte, 0),
+		Path:     path,
+		Workers:  DefaultWorkers(),
+		ReadOnly: readOnly,
+		lock:     lock,
+	}
+	err = storage.load(hashAlgorithm)
+	if err != nil {
+		storage.CloseStorage()
+		return nil, err
 	}
+	return storage, nil
+}
 
-	branch, err := storage.GetData([]byte(BRANCH_KEY))
+// Load metadata, current branch and refs. Empty repository is initialized when
+// hashAlgorithm is given.
+func (s *Storage) load(hashAlgorithm string) error {
+	branch, err := s.GetData([]byte(BRANCH_KEY))
 	if err == badger.ErrKeyNotFound {
-		fmt.Println("BRANCH not found. Initializing BRANCH...")
+		if s.ReadOnly || hashAlgorithm == "" {
+			return fmt.Errorf("repository is not initialized")
+		}
 		//create init commit
 		tree := object.Tree{
 			Children: []object.Child{},
 		}
 		treeObj, err := tree.CreateObject()
 		if err != nil {
-			return nil, err
+			return err
 		}
 		treeHash, treeData, err := treeObj.GetData()
 		if err != nil {
-			return nil, err
-		}
-		err = storage.SetData(treeHash, treeData)
-		if err != nil {
-			return nil, err
+			return err
 		}
 		commit := object.Commit{
 			Origin:      []byte{},
@@ -80,50 +170,72 @@ func InitStorage(path string) (*Storage, error) {
 		}
 		commitObj, err := commit.CreateObject()
 		if err != nil {
-			return nil, err
+			return err
 		}
 		commitHash, commitData, err := commitObj.GetData()
 		if err != nil {
-			return nil, err
-		}
-		err = storage.SetData(commitHash, commitData)
-		if err != nil {
-			return nil, err
+			return err
 		}
-		// fmt.Print("COMMIT-ORIGIN: ", commit.Origin)
 		//initialize branches
-		storage.Branch = MASTER_BRANCH
-		storage.Refs[MASTER_BRANCH] = commitHash
-
-		err = storage.SetData([]byte(BRANCH_KEY), []byte(storage.Branch))
+		refs := map[string][]byte{MASTER_BRANCH: commitHash}
+		refsData, err := SerializeRefs(refs)
 		if err != nil {
-			return nil, err
+			return err
 		}
-
-		refsData, err := SerializeRefs(storage.Refs)
+		meta := newMetadata(hashAlgorithm)
+		metaData, err := SerializeMetadata(meta)
 		if err != nil {
-			return nil, err
+			return err
 		}
-		err = storage.SetData([]byte(REFS_KEY), refsData)
+		err = s.DB.Update(func(txn *badger.Txn) error {
+			if err := txn.Set([]byte(META_KEY), metaData); err != nil {
+				return err
+			}
+			if err := txn.Set(treeHash, treeData); err != nil {
+				return err
+			}
+			if err := txn.Set(commitHash, commitData); err != nil {
+				return err
+			}
+			if err := txn.Set([]byte(BRANCH_KEY), []byte(MASTER_BRANCH)); err != nil {
+				return err
+			}
+			if err := txn.Set([]byte(REFS_KEY), refsData); err != nil {
+				return err
+			}
+			entry := newReflogEntry(nil, commitHash, "", "commit (initial): "+INITIAL_COMMIT)
+			if err := appendReflog(txn, MASTER_BRANCH, entry); err != nil {
+				return err
+			}
+			return appendReflog(txn, HEAD, entry)
+		})
 		if err != nil {
-			return nil, err
+			return err
 		}
-		return storage, nil
+		s.Branch = MASTER_BRANCH
+		s.Refs = refs
+		s.Meta = meta
+		return nil
 	}
-	refsData, err := storage.GetData([]byte(REFS_KEY))
 	if err != nil {
-		return nil, err
+		return err
+	}
+	err = s.loadMetadata()
+	if err != nil {
+		return err
+	}
+	refsData, err := s.GetData([]byte(REFS_KEY))
+	if err != nil {
+		return err
 	}
 
-	fmt.Printf("BRANCH found: %s\n", branch)
-	storage.Branch = string(branch)
+	s.Branch = string(branch)
 	refs, err := DeserializeRefs(refsData)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	storage.Refs = refs
-
-	return storage, nil
+	s.Refs = refs
+	return nil
 }
 
 // Get data from database for this key
@@ -154,49 +266,81 @@ func (s *Storage) SetData(key []byte, data []byte) error {
 	return err
 }
 
-// Close database
+// Close database and release repository lock
 func (s *Storage) CloseStorage() {
 	s.DB.Close()
+	if s.lock != nil {
+		s.lock.release()
+		s.lock = nil
+	}
 }
 
-func (s *Storage) CreateCommit(author string, description string) error {
-	fs, err := InitFileSystem(s.Path)
+// Create commit of current file system state. While operation replaying commits is in
+// progress, its own commits are made by continuing it.
+func (s *Storage) CreateCommit(author string, description string) (*CommitResult, error) {
+	seq, err := s.GetSequencer()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	for _, obj := range fs.TreeMap {
-		hash, data, err := obj.GetData()
-		if err != nil {
-			return err
-		}
-		s.SetData(hash, data)
+	if seq != nil {
+		return nil, seq.InProgress()
 	}
+	fs, err := InitFileSystem(s.Path, s.Workers)
+	if err != nil {
+		return nil, err
+	}
+	return s.CommitTree(fs.TreeMap, fs.ROOT_HASH, author, description, author, "commit")
+}
+
+// Create commit of tree on current branch, recording operation in logs with committer as
+// user who moved the branch, current user if empty. Only objects missing in database are
+// written, the commit and the updated refs are written after them in one transaction, so
+// an interrupted commit never leaves a ref pointing at missing objects.
+func (s *Storage) CommitTree(objects map[string]*object.Object, tree []byte, author string, description string, committer string, operation string) (*CommitResult, error) {
 	commit := object.Commit{
 		Origin:      s.Refs[s.Branch],
-		Tree:        fs.ROOT_HASH,
+		Tree:        tree,
 		Author:      []byte(author),
 		Description: []byte(description),
 		Time:        time.Now().Unix(),
 	}
 	commitObj, err := commit.CreateObject()
 	if err != nil {
-		return err
+		return nil, err
 	}
 	commitHash, commitData, err := commitObj.GetData()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	err = s.SetData(commitHash, commitData)
+	refs := s.copyRefs()
+	refs[s.Branch] = commitHash
+	refsData, err := SerializeRefs(refs)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	s.Refs[s.Branch] = commitHash
-	refsData, err := SerializeRefs(s.Refs)
+
+	stored, reused, err := s.StoreObjects(objects)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	err = s.SetData([]byte(REFS_KEY), refsData)
-	return err
+	err = s.DB.Update(func(txn *badger.Txn) error {
+		if err := txn.Set(commitHash, commitData); err != nil {
+			return err
+		}
+		if err := txn.Set([]byte(REFS_KEY), refsData); err != nil {
+			return err
+		}
+		entry := newReflogEntry(s.Refs[s.Branch], commitHash, committer, operation+": "+summary(description))
+		if err := appendReflog(txn, s.Branch, entry); err != nil {
+			return err
+		}
+		return appendReflog(txn, HEAD, entry)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.Refs = refs
+	return &CommitResult{commitHash, stored, reused}, nil
 }
 
 // TODO поправить
@@ -233,10 +377,89 @@ func (s *Storage) GetCommit(hash []byte) (*CommitData, error) {
 	return &commitData, err
 }
 
+// Entry of tree at slash separated path, nil if there is none
+func (s *Storage) FindEntry(tree []byte, path string) (*object.Child, error) {
+	var entry *object.Child
+	for _, name := range strings.Split(strings.Trim(path, "/"), "/") {
+		if entry != nil {
+			if entry.Type != object.TypeTree {
+				return nil, nil
+			}
+			tree = entry.Hash
+		}
+		obj, err := s.GetObject(tree)
+		if err != nil {
+			return nil, err
+		}
+		t, err := obj.ParseTree()
+		if err != nil {
+			return nil, err
+		}
+		entry = nil
+		for i := range t.Children {
+			if string(t.Children[i].Name) == name {
+				entry = &t.Children[i]
+				break
+			}
+		}
+		if entry == nil {
+			return nil, nil
+		}
+	}
+	return entry, nil
+}
+
+// Check that commit is the empty initial commit written when repository was created.
+// It holds no files, so branch pointing at it may be replaced by imported history.
+func (s *Storage) IsInitialCommit(hash []byte) (bool, error) {
+	commitData, err := s.GetCommit(hash)
+	if err != nil {
+		return false, err
+	}
+	commit := commitData.Commit
+	if len(commit.Origin) > 0 || len(commit.Merges) > 0 || string(commit.Description) != INITIAL_COMMIT {
+		return false, nil
+	}
+	obj, err := s.GetObject(commit.Tree)
+	if err != nil {
+		return false, err
+	}
+	tree, err := obj.ParseTree()
+	if err != nil {
+		return false, err
+	}
+	return len(tree.Children) == 0, nil
+}
+
+// Check if commit ancestor is reachable from commit by parents, including merged ones.
+// Commit is ancestor of itself.
+func (s *Storage) IsAncestor(ancestor []byte, commit []byte) (bool, error) {
+	visited := make(map[string]bool)
+	queue := [][]byte{commit}
+	for len(queue) > 0 {
+		hash := queue[0]
+		queue = queue[1:]
+		if bytes.Equal(hash, ancestor) {
+			return true, nil
+		}
+		if len(hash) == 0 || visited[string(hash)] {
+			continue
+		}
+		visited[string(hash)] = true
+		commitData, err := s.GetCommit(hash)
+		if err != nil {
+			return false, err
+		}
+		queue = append(queue, commitData.Commit.Origin)
+		queue = append(queue, commitData.Commit.Merges...)
+	}
+	return false, nil
+}
+
 // find diffs between file system stored in database and real file system
 func (s *Storage) Diffs() ([]*object.FileChange, error) {
 	fileChange := make([]*object.FileChange, 0)
-	fs, err := InitFileSystem(s.Path)
+	fs, err := InitFileSystem(s.Path, s.Workers)
 	if err != nil {
 		return nil, err
 	}
@@ -256,7 +479,7 @@ func (s *Storage) Diffs() ([]*object.FileChange, error) {
 }
 func (s *Storage) DiffsWithCommit(hash []byte) ([]*object.FileChange, error) {
 	fileChange := make([]*object.FileChange, 0)
-	fs, err := InitFileSystem(s.Path)
+	fs, err := InitFileSystem(s.Path, s.Workers)
 	if err != nil {
 		return nil, err
 	}
@@ -296,6 +519,18 @@ func (s *Storage) GetObject(key []byte) (*object.Object, error) {
 	return object.DeserializeObject(objData)
 }
 
+// Check if object is stored in database
+func (s *Storage) HasObject(key []byte) (bool, error) {
+	err := s.DB.View(func(txn *badger.Txn) error {
+		_, err := txn.Get(key)
+		return err
+	})
+	if err == badger.ErrKeyNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
 func (s *Storage) SetObject(obj *object.Object) error {
 	objKey, objData, err := obj.GetData()
 	if err != nil {
@@ -305,11 +540,98 @@ func (s *Storage) SetObject(obj *object.Object) error {
 	return err
 }
 
+// Write objects missing in database, keyed by hex hash as in FileSystem.TreeMap.
+// Existing objects are neither compressed nor rewritten.
+func (s *Storage) StoreObjects(objects map[string]*object.Object) (stored int, reused int, err error) {
+	missing := make(map[string]*object.Object)
+	err = s.DB.View(func(txn *badger.Txn) error {
+		for key, obj := range objects {
+			hash, err := hex.DecodeString(key)
+			if err != nil {
+				return err
+			}
+			_, err = txn.Get(hash)
+			if err == badger.ErrKeyNotFound {
+				missing[key] = obj
+				continue
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	wb := s.DB.NewWriteBatch()
+	defer wb.Cancel()
+	for key, obj := range missing {
+		hash, _ := hex.DecodeString(key)
+		data, err := obj.GetZippedData()
+		if err != nil {
+			return 0, 0, err
+		}
+		err = wb.Set(hash, data)
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	err = wb.Flush()
+	if err != nil {
+		return 0, 0, err
+	}
+	return len(missing), len(objects) - len(missing), nil
+}
+
+// Point branches to given commits in one transaction, creating missing ones and recording
+// operation in their logs. Objects of commits must already be stored.
+func (s *Storage) UpdateBranches(updates map[string][]byte, operation string) error {
+	refs := s.copyRefs()
+	for branch, hash := range updates {
+		if s.Refs[branch] == nil {
+			if err := CheckBranchName(branch); err != nil {
+				return err
+			}
+		}
+		refs[branch] = hash
+	}
+	refsData, err := SerializeRefs(refs)
+	if err != nil {
+		return err
+	}
+	err = s.DB.Update(func(txn *badger.Txn) error {
+		if err := txn.Set([]byte(REFS_KEY), refsData); err != nil {
+			return err
+		}
+		for branch, hash := range updates {
+			entry := newReflogEntry(s.Refs[branch], hash, "", operation)
+			if err := appendReflog(txn, branch, entry); err != nil {
+				return err
+			}
+			if branch == s.Branch {
+				if err := appendReflog(txn, HEAD, entry); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	s.Refs = refs
+	return nil
+}
+
+// Names of branches in alphabetical order
 func (s *Storage) GetBranches() []string {
 	branches := make([]string, 0, len(s.Refs))
 	for k := range s.Refs {
 		branches = append(branches, k)
 	}
+	sort.Strings(branches)
 	return branches
 }
 
@@ -317,11 +639,16 @@ func (s *Storage) ChangeBranch(branch string) error {
 	if s.Refs[branch] == nil {
 		return fmt.Errorf("branch \"%s\" does not exist", branch)
 	}
-	s.Branch = branch
-	err := s.SetData([]byte(BRANCH_KEY), []byte(s.Branch))
+	err := s.DB.Update(func(txn *badger.Txn) error {
+		if err := txn.Set([]byte(BRANCH_KEY), []byte(branch)); err != nil {
+			return err
+		}
+		return appendReflog(txn, HEAD, s.checkoutEntry(branch))
+	})
 	if err != nil {
 		return err
 	}
+	s.Branch = branch
 	return nil
 }
 
@@ -329,14 +656,91 @@ func (s *Storage) CreateBranch(branch string) error {
 	if s.Refs[branch] != nil {
 		return fmt.Errorf("branch \"%s\" already exists", branch)
 	}
-	s.Refs[branch] = s.Refs[s.Branch]
-	refsData, err := SerializeRefs(s.Refs)
+	if err := CheckBranchName(branch); err != nil {
+		return err
+	}
+	refs := s.copyRefs()
+	refs[branch] = s.Refs[s.Branch]
+	refsData, err := SerializeRefs(refs)
 	if err != nil {
 		return err
 	}
-	err = s.SetData([]byte(REFS_KEY), refsData)
+	err = s.DB.Update(func(txn *badger.Txn) error {
+		if err := txn.Set([]byte(REFS_KEY), refsData); err != nil {
+			return err
+		}
+		return appendReflog(txn, branch, s.branchEntry())
+	})
 	if err != nil {
 		return err
 	}
+	s.Refs = refs
 	return nil
 }
+
+// Create branch from current one and switch to it in a single transaction
+func (s *Storage) CreateAndChangeBranch(branch string) error {
+	if s.Refs[branch] != nil {
+		return fmt.Errorf("branch \"%s\" already exists", branch)
+	}
+	if err := CheckBranchName(branch); err != nil {
+		return err
+	}
+	seq, err := s.GetSequencer()
+	if err != nil {
+		return err
+	}
+	if seq != nil {
+		return seq.InProgress()
+	}
+	refs := s.copyRefs()
+	refs[branch] = s.Refs[s.Branch]
+	refsData, err := SerializeRefs(refs)
+	if err != nil {
+		return err
+	}
+	err = s.DB.Update(func(txn *badger.Txn) error {
+		if err := txn.Set([]byte(REFS_KEY), refsData); err != nil {
+			return err
+		}
+		if err := txn.Set([]byte(BRANCH_KEY), []byte(branch)); err != nil {
+			return err
+		}
+		if err := appendReflog(txn, branch, s.branchEntry()); err != nil {
+			return err
+		}
+		return appendReflog(txn, HEAD, s.checkoutEntry(branch))
+	})
+	if err != nil {
+		return err
+	}
+	s.Refs = refs
+	s.Branch = branch
+	return nil
+}
+
+// Log entry for branch created from current one
+func (s *Storage) branchEntry() *ReflogEntry {
+	tip := s.Refs[s.Branch]
+	return newReflogEntry(nil, tip, "", "branch: Created from "+s.Branch)
+}
+
+// Log entry for switching from current branch
+func (s *Storage) checkoutEntry(branch string) *ReflogEntry {
+	old := s.Refs[s.Branch]
+	// created branch points to the same commit as current one
+	new := s.Refs[branch]
+	if new == nil {
+		new = old
+	}
+	return newReflogEntry(old, new, "", fmt.Sprintf("checkout: moving from %s to %s", s.Branch, branch))
+}
+
+// Copy of refs, so in-memory state changes only after successful write
+func (s *Storage) copyRefs() map[string][]byte {
+	refs := make(map[string][]byte, len(s.Refs)+1)
+	for k, v := range s.Refs {
+		refs[k] = v
+	}
+	return refs
+}
@@ -0,0 +1,342 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mymodule/internal/object"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Error of operations that would lose changes of working tree
+var ErrDirtyWorkTree = errors.New("working tree has uncommitted changes, commit them first")
+
+// Switch branch and replace files of working tree with files of its last commit.
+// Working tree must have no uncommitted changes and no operation may be in progress.
+// Branch is switched before files are written, so if writing fails, working tree is
+// only left dirty on the new branch and "reset --hard" restores it.
+func (s *Storage) Checkout(branch string) error {
+	if s.Refs[branch] == nil {
+		return fmt.Errorf("branch \"%s\" does not exist", branch)
+	}
+	seq, err := s.GetSequencer()
+	if err != nil {
+		return err
+	}
+	if seq != nil {
+		return seq.InProgress()
+	}
+	fs, err := s.CleanWorkTree()
+	if err != nil {
+		return err
+	}
+	err = s.ChangeBranch(branch)
+	if err != nil {
+		return err
+	}
+	return s.WriteBranchFiles(fs, branch)
+}
+
+// Scanned working tree, when current branch may be moved by command other than checkout
+// or reset: working tree is clean and no operation is in progress. Files are written by
+// WriteBranchFiles after branch is moved.
+func (s *Storage) MovableWorkTree() (*FileSystem, error) {
+	seq, err := s.GetSequencer()
+	if err != nil {
+		return nil, err
+	}
+	if seq != nil {
+		return nil, fmt.Errorf("%s is in progress", seq.Operation)
+	}
+	return s.CleanWorkTree()
+}
+
+// Make working tree scanned into fs match last commit of branch that has just been
+// checked out or moved. If writing fails, error tells how to restore files.
+func (s *Storage) WriteBranchFiles(fs *FileSystem, branch string) error {
+	commitData, err := s.GetCommit(s.Refs[branch])
+	if err != nil {
+		return err
+	}
+	err = s.UpdateWorkTree(fs, commitData.Commit.Tree)
+	if err != nil {
+		return fmt.Errorf("%w\nfiles of branch \"%s\" are written partly, restore them with \"reset --hard -f\"", err, branch)
+	}
+	return nil
+}
+
+// Modes of Reset
+const (
+	ResetSoft  = "soft"  //Move branch only
+	ResetMixed = "mixed" //The same as soft, there is no index to reset
+	ResetHard  = "hard"  //Move branch and make working tree match commit
+)
+
+// Move current branch to commit. Hard reset also makes working tree match commit: files
+// and directories missing in commit are removed and files that differ from commit are
+// overwritten, so changes of files of current commit are lost. Untracked files, ones
+// missing in current commit, are removed or overwritten only with force; untracked files
+// equal to files of commit are left as they are. Branch is moved before files are
+// written. No operation may be in progress.
+func (s *Storage) Reset(hash []byte, mode string, force bool) error {
+	seq, err := s.GetSequencer()
+	if err != nil {
+		return err
+	}
+	if seq != nil {
+		return seq.InProgress()
+	}
+	commitData, err := s.GetCommit(hash)
+	if err != nil {
+		return err
+	}
+	var fs *FileSystem
+	if mode == ResetHard {
+		fs, err = InitFileSystem(s.Path, s.Workers)
+		if err != nil {
+			return err
+		}
+		untracked, err := s.lostUntracked(fs, commitData.Commit.Tree)
+		if err != nil {
+			return err
+		}
+		if len(untracked) > 0 && !force {
+			return fmt.Errorf("untracked files would be removed or overwritten: %s, commit them or force reset", strings.Join(untracked, ", "))
+		}
+	}
+	if !bytes.Equal(s.Refs[s.Branch], hash) {
+		err = s.UpdateBranches(map[string][]byte{s.Branch: hash}, fmt.Sprintf("reset: moving to %x", hash))
+		if err != nil {
+			return err
+		}
+	}
+	if fs == nil {
+		return nil
+	}
+	return s.WriteBranchFiles(fs, s.Branch)
+}
+
+// Paths of working tree scanned into fs that are missing in current commit and differ
+// from tree, so making working tree match tree would remove or overwrite them
+func (s *Storage) lostUntracked(fs *FileSystem, tree []byte) ([]string, error) {
+	headData, err := s.GetCommit(s.Refs[s.Branch])
+	if err != nil {
+		return nil, err
+	}
+	cmp := object.Comparator{
+		GetFunction1: s.GetObject,
+		GetFunction2: fs.GetObject,
+		EmptyFiles:   true,
+	}
+	added, err := cmp.CompareTrees(headData.Commit.Tree, fs.ROOT_HASH)
+	if err != nil {
+		return nil, err
+	}
+	differ, err := cmp.CompareTrees(tree, fs.ROOT_HASH)
+	if err != nil {
+		return nil, err
+	}
+	// files deleted from working tree are written back, nothing is lost
+	lost := make(map[string]bool)
+	for _, c := range differ {
+		if c.Status != object.StatusDeleted {
+			lost[string(c.FileName)] = true
+		}
+	}
+	untracked := make([]string, 0)
+	for _, c := range added {
+		if c.Status == object.StatusAdded && lost[string(c.FileName)] {
+			untracked = append(untracked, string(c.FileName))
+		}
+	}
+	return untracked, nil
+}
+
+// Scan working tree and check that it matches current commit
+func (s *Storage) CleanWorkTree() (*FileSystem, error) {
+	fs, err := InitFileSystem(s.Path, s.Workers)
+	if err != nil {
+		return nil, err
+	}
+	commitData, err := s.GetCommit(s.Refs[s.Branch])
+	if err != nil {
+		return nil, err
+	}
+	same, err := s.isWorkTreeAt(fs, commitData.Commit.Tree)
+	if err != nil {
+		return nil, err
+	}
+	if !same {
+		return nil, ErrDirtyWorkTree
+	}
+	return fs, nil
+}
+
+// Check that working tree scanned into fs has the same files as stored tree. Working
+// tree is hashed in current encoding, so in repository with gob encoded objects hashes
+// may differ for the same files and files are compared by content.
+func (s *Storage) isWorkTreeAt(fs *FileSystem, tree []byte) (bool, error) {
+	if bytes.Equal(tree, fs.ROOT_HASH) {
+		return true, nil
+	}
+	if s.Meta.ObjectEncoding == object.EncodingVersion {
+		return false, nil
+	}
+	cmp := object.Comparator{
+		GetFunction1: s.GetObject,
+		GetFunction2: fs.GetObject,
+		EmptyFiles:   true,
+	}
+	return cmp.SameTrees(tree, fs.ROOT_HASH)
+}
+
+// Make working tree scanned into fs match stored tree. Only entries that differ are
+// written or removed.
+func (s *Storage) UpdateWorkTree(fs *FileSystem, tree []byte) error {
+	return s.updateDir(fs, s.Path, fs.ROOT_HASH, tree)
+}
+
+// Update directory from its scanned tree (from) to stored tree (to)
+func (s *Storage) updateDir(fs *FileSystem, dir string, from []byte, to []byte) error {
+	if bytes.Equal(from, to) {
+		return nil
+	}
+	current, err := fsChildren(fs, from)
+	if err != nil {
+		return err
+	}
+	target, err := s.storedChildren(to)
+	if err != nil {
+		return err
+	}
+
+	for name, o := range current {
+		n, ok := target[name]
+		if ok && n.Type == o.Type {
+			continue
+		}
+		path, err := s.workTreePath(dir, name)
+		if err != nil {
+			return err
+		}
+		err = os.RemoveAll(path)
+		if err != nil {
+			return err
+		}
+	}
+	for name, n := range target {
+		path, err := s.workTreePath(dir, name)
+		if err != nil {
+			return err
+		}
+		o, ok := current[name]
+		if ok && o.Type != n.Type {
+			ok = false
+		}
+		switch n.Type {
+		case object.TypeTree:
+			var fromHash []byte
+			if ok {
+				fromHash = o.Hash
+			} else {
+				err = os.Mkdir(path, 0755)
+				if err != nil {
+					return err
+				}
+			}
+			err = s.updateDir(fs, path, fromHash, n.Hash)
+		case object.TypeBlob:
+			if ok && bytes.Equal(o.Hash, n.Hash) && o.FileMode() == n.FileMode() {
+				continue
+			}
+			err = s.writeFile(path, n)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Children of scanned tree by name, none for nil hash
+func fsChildren(fs *FileSystem, hash []byte) (map[string]object.Child, error) {
+	children := make(map[string]object.Child)
+	if hash == nil {
+		return children, nil
+	}
+	obj, err := fs.GetObject(hash)
+	if err != nil {
+		return nil, err
+	}
+	if obj == nil {
+		return nil, fmt.Errorf("tree %x is not scanned", hash)
+	}
+	tree, err := obj.ParseTree()
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range tree.Children {
+		children[string(c.Name)] = c
+	}
+	return children, nil
+}
+
+// Children of stored tree by name
+func (s *Storage) storedChildren(hash []byte) (map[string]object.Child, error) {
+	children := make(map[string]object.Child)
+	obj, err := s.GetObject(hash)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := obj.ParseTree()
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range tree.Children {
+		children[string(c.Name)] = c
+	}
+	return children, nil
+}
+
+// Path of entry of directory of working tree. Entry names come from stored trees, so
+// path that would leave working tree is refused.
+func (s *Storage) workTreePath(dir string, name string) (string, error) {
+	err := object.CheckEntryName([]byte(name))
+	if err != nil {
+		return "", err
+	}
+	path := dir + "/" + name
+	rel, err := filepath.Rel(s.Path, path)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path %s is outside of working tree", path)
+	}
+	return path, nil
+}
+
+// Write file, executable or symlink for tree entry, replacing existing one
+func (s *Storage) writeFile(path string, c object.Child) error {
+	if _, err := s.workTreePath(filepath.Dir(path), filepath.Base(path)); err != nil {
+		return err
+	}
+	obj, err := s.GetObject(c.Hash)
+	if err != nil {
+		return err
+	}
+	blob, err := obj.ParseBlob()
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	switch c.FileMode() {
+	case object.ModeSymlink:
+		return os.Symlink(string(blob.Data), path)
+	case object.ModeExecutable:
+		return os.WriteFile(path, blob.Data, 0755)
+	}
+	return os.WriteFile(path, blob.Data, 0644)
+}
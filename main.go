@@ -4,24 +4,69 @@ import (
 	"bufio"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"mymodule/internal/cmd"
 
 	"github.com/xlab/closer"
 )
 
-const path = "tmp"
-
 var cli *cmd.CLI
 
+// Shell is running, so exit is reported to user
+var interactive bool
+
+// Without arguments or with "shell" commands are read from stdin, otherwise arguments
+// are run as one command and exit code reports its result. Options before command:
+// -C <dir>, --repo <dir> - work with repository containing dir instead of current directory.
 func main() {
+	dir, args, err := parseOptions(os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(closer.ExitCodeErr)
+	}
 	closer.Bind(cleanup)
-	go initCLI()
+	if len(args) == 0 || args[0] == "shell" {
+		interactive = true
+		go runShell(dir)
+	} else {
+		go runCommand(dir, args)
+	}
 	closer.Hold()
 }
 
-func initCLI() {
-	cli = cmd.InitCLI(path)
+// Split global options from command
+func parseOptions(args []string) (string, []string, error) {
+	dir := "."
+	for len(args) > 0 {
+		name, value, hasValue := strings.Cut(args[0], "=")
+		if name != "--repo" {
+			name, hasValue = args[0], false
+		}
+		if name != "-C" && name != "--repo" {
+			break
+		}
+		if !hasValue {
+			if len(args) < 2 {
+				return "", nil, fmt.Errorf("Option %s requires directory.", name)
+			}
+			value = args[1]
+			args = args[1:]
+		}
+		// several options are resolved one after another, as cd would do
+		if filepath.IsAbs(value) {
+			dir = value
+		} else {
+			dir = filepath.Join(dir, value)
+		}
+		args = args[1:]
+	}
+	return dir, args, nil
+}
+
+func runShell(dir string) {
+	cli = cmd.InitCLI(dir)
 
 	scanner := bufio.NewScanner(os.Stdin)
 	fmt.Print("> ")
@@ -29,8 +74,27 @@ func initCLI() {
 		cli.Execute(scanner.Text())
 		fmt.Print("> ")
 	}
+	// end of input
+	fmt.Println()
+	closer.Close()
+}
+
+func runCommand(dir string, args []string) {
+	cli = cmd.InitCLI(dir)
+	code := closer.ExitCodeOK
+	if cli.ExecuteArgs(args) != nil {
+		code = closer.ExitCodeErr
+	}
+	closer.Exit(code)
 }
+
 func cleanup() {
+	if !interactive {
+		if cli != nil {
+			cli.Close()
+		}
+		return
+	}
 	fmt.Println("Closing DB...")
 	if cli != nil {
 		cli.Exit()